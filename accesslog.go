@@ -0,0 +1,174 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// The formats of the access log.
+const (
+	CommonLogFormat   = "common"   // Apache Common Log Format
+	CombinedLogFormat = "combined" // Apache Combined Log Format
+	JSONLogFormat     = "json"     // JSON lines
+)
+
+// AccessLogEntry describes the request in the access log. Its fields are
+// available in the template of the access log format.
+type AccessLogEntry struct {
+	Time      time.Time              `json:"time"`
+	Method    string                 `json:"method"`
+	URI       string                 `json:"uri"`
+	Path      string                 `json:"path"`
+	Proto     string                 `json:"proto"`
+	Route     string                 `json:"route,omitempty"`
+	Status    int                    `json:"status"`
+	Size      int64                  `json:"size"`
+	Duration  time.Duration          `json:"-"`
+	RealIP    string                 `json:"ip"`
+	User      string                 `json:"user,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	Referer   string                 `json:"referer,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler interface. The duration is written in
+// milliseconds.
+func (e *AccessLogEntry) MarshalJSON() ([]byte, error) {
+	type entry AccessLogEntry
+	return json.Marshal(&struct {
+		*entry
+		Duration float64 `json:"duration_ms"`
+	}{
+		entry:    (*entry)(e),
+		Duration: float64(e.Duration) / float64(time.Millisecond),
+	})
+}
+
+// newAccessLogEntry returns the access log entry of the completed request.
+func newAccessLogEntry(c *Context, started time.Time, err error) *AccessLogEntry {
+	var r = c.Request
+	var entry = &AccessLogEntry{
+		Time:      started,
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Path:      r.URL.Path,
+		Proto:     r.Proto,
+		Route:     c.route,
+		Status:    c.Status(),
+		Size:      c.ContentLength(),
+		Duration:  time.Since(started),
+		RealIP:    c.RealIP(),
+		UserAgent: r.UserAgent(),
+		Referer:   r.Referer(),
+		RequestID: c.RequestID(),
+	}
+	if entry.URI == "" {
+		entry.URI = r.URL.RequestURI()
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		entry.User = user
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if len(c.logFields) > 0 {
+		entry.Fields = make(map[string]interface{}, len(c.logFields)/2)
+		for i := 0; i+1 < len(c.logFields); i += 2 {
+			var value = c.logFields[i+1]
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			entry.Fields[fmt.Sprint(c.logFields[i])] = value
+		}
+	}
+	return entry
+}
+
+// AccessLog writes the access log of ServeMux in the Format to the Writer. The
+// Format is CommonLogFormat (default), CombinedLogFormat, JSONLogFormat or
+// text/template with the fields of AccessLogEntry:
+//
+//	mux.AccessLog = &rest.AccessLog{
+//		Writer: &rest.RotateFile{Filename: "access.log", Interval: 24 * time.Hour},
+//		Format: `{{.RealIP}} {{.Method}} {{.Route}} {{.Status}} {{.Duration}}`,
+//	}
+//
+// Each entry is written as the single line. It is safe for concurrent use.
+type AccessLog struct {
+	Writer io.Writer // output
+	Format string    // log format
+	once   sync.Once
+	tmpl   *template.Template
+	err    error
+	mu     sync.Mutex
+	buf    bytes.Buffer
+}
+
+// Log writes the entry to the access log.
+func (l *AccessLog) Log(entry *AccessLogEntry) error {
+	l.once.Do(func() {
+		switch l.Format {
+		case "", CommonLogFormat, CombinedLogFormat, JSONLogFormat:
+		default:
+			l.tmpl, l.err = template.New("access").Parse(l.Format)
+		}
+	})
+	if l.err != nil {
+		return l.err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf.Reset()
+	switch l.Format {
+	case "", CommonLogFormat:
+		writeCommonLog(&l.buf, entry)
+	case CombinedLogFormat:
+		writeCommonLog(&l.buf, entry)
+		fmt.Fprintf(&l.buf, " %s %s", quoteLogValue(entry.Referer),
+			quoteLogValue(entry.UserAgent))
+	case JSONLogFormat:
+		if err := json.NewEncoder(&l.buf).Encode(entry); err != nil {
+			return err
+		}
+	default:
+		if err := l.tmpl.Execute(&l.buf, entry); err != nil {
+			return err
+		}
+	}
+	if data := l.buf.Bytes(); len(data) == 0 || data[len(data)-1] != '\n' {
+		l.buf.WriteByte('\n')
+	}
+	_, err := l.Writer.Write(l.buf.Bytes())
+	return err
+}
+
+// writeCommonLog writes the entry in Apache Common Log Format.
+func writeCommonLog(w io.Writer, entry *AccessLogEntry) {
+	var user, size = "-", "-"
+	if entry.User != "" {
+		user = entry.User
+	}
+	if entry.Size > 0 {
+		size = strconv.FormatInt(entry.Size, 10)
+	}
+	fmt.Fprintf(w, "%s - %s [%s] %s %d %s", entry.RealIP, user,
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(entry.Method+" "+entry.URI+" "+entry.Proto),
+		entry.Status, size)
+}
+
+// quoteLogValue returns the quoted value or "-" if it is empty.
+func quoteLogValue(value string) string {
+	if value == "" {
+		return `"-"`
+	}
+	return strconv.Quote(value)
+}
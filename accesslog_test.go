@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	var mux = &ServeMux{AccessLog: &AccessLog{Writer: &buf}}
+	mux.Handle("GET", "/user/:name", func(c *Context) error {
+		c.AddLogField("name", c.Param("name"))
+		return c.Write("OK")
+	})
+	var request = func() {
+		r := httptest.NewRequest("GET", "/user/test?q=1", nil)
+		r.Header.Set("User-Agent", "test agent")
+		r.SetBasicAuth("user", "password")
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	for _, test := range []struct {
+		format string
+		line   string
+	}{
+		{"", `^192\.0\.2\.1 - user \[[^\]]+\] "GET /user/test\?q=1 HTTP/1\.1" 200 2\n$`},
+		{CombinedLogFormat, `^192\.0\.2\.1 - user \[[^\]]+\] "GET /user/test\?q=1 HTTP/1\.1" 200 2 "-" "test agent"\n$`},
+		{"{{.Method}} {{.Route}} {{.Status}} {{.Fields.name}}", `^GET /user/:name 200 test\n$`},
+	} {
+		buf.Reset()
+		mux.AccessLog = &AccessLog{Writer: &buf, Format: test.format}
+		request()
+		if !regexp.MustCompile(test.line).MatchString(buf.String()) {
+			t.Errorf("bad %q log line: %q", test.format, buf.String())
+		}
+	}
+
+	buf.Reset()
+	mux.AccessLog = &AccessLog{Writer: &buf, Format: JSONLogFormat}
+	request()
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["route"] != "/user/:name" || entry["status"] != 200.0 ||
+		entry["user_agent"] != "test agent" || entry["duration_ms"] == nil ||
+		entry["fields"].(map[string]interface{})["name"] != "test" {
+		t.Error("bad json entry:", entry)
+	}
+
+	mux.AccessLog = &AccessLog{Writer: &buf, Format: "{{.Bad"}
+	if err := mux.AccessLog.Log(&AccessLogEntry{}); err == nil ||
+		!strings.Contains(err.Error(), "access") {
+		t.Error("bad template error:", err)
+	}
+}
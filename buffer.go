@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"math"
+	"net/http"
+)
+
+// BufferResponse returns the Handler enabling the buffered mode of the
+// response for the route. It is used before other route handlers. The same
+// can be enabled for all routes with ServeMux.BufferSize.
+//
+// In the buffered mode the output is held until the handler returns, if it
+// does not exceed the limit (zero or negative means no limit). So the error
+// returned by the handler replaces the partial output, Content-Length is set
+// exactly, even for the compressed response, and the middleware can inspect
+// or transform the body with Context.ResponseBody and SetResponseBody:
+//
+//	func minify(h rest.Handler) rest.Handler {
+//		return func(c *rest.Context) error {
+//			if err := h(c); err != nil {
+//				return err
+//			}
+//			if body, ok := c.ResponseBody(); ok {
+//				c.SetResponseBody(minifyHTML(body))
+//			}
+//			return nil
+//		}
+//	}
+//
+// The larger responses and the responses flushed with Flush are sent as usual.
+func BufferResponse(limit int) Handler {
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	return func(c *Context) error {
+		c.Response.(*response).bufferLimit = limit
+		return nil
+	}
+}
+
+// ResponseBody returns the uncompressed response body held in the buffered
+// mode. It returns false, if the response is not buffered or was already sent.
+func (c *Context) ResponseBody() ([]byte, bool) {
+	var rw = c.Response.(*response)
+	if rw.bufferLimit == 0 || !rw.buffered {
+		return nil, false
+	}
+	return rw.buffer, true
+}
+
+// SetResponseBody replaces the response body held in the buffered mode. It
+// returns false, if the response is not buffered or was already sent.
+func (c *Context) SetResponseBody(data []byte) bool {
+	var rw = c.Response.(*response)
+	if rw.bufferLimit == 0 || !rw.buffered {
+		return false
+	}
+	rw.buffer = append(rw.buffer[:0], data...)
+	rw.Header().Del("Content-Length")
+	return true
+}
+
+// ResetResponse discards the response held in the buffered mode: the status,
+// the body and its representation headers. After that another response can be
+// written. It returns false, if the response is not buffered or was already
+// sent.
+func (c *Context) ResetResponse() bool {
+	var rw = c.Response.(*response)
+	if rw.bufferLimit == 0 || !rw.buffered {
+		return false
+	}
+	rw.buffer, rw.buffered, rw.wroteHeader = nil, false, false
+	rw.code = http.StatusOK
+	var headers = rw.Header()
+	for _, key := range []string{"Content-Type", "Content-Length",
+		"Content-Encoding", "ETag", "Last-Modified"} {
+		headers.Del(key)
+	}
+	return true
+}
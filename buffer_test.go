@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBufferResponse(t *testing.T) {
+	var mux = new(ServeMux)
+	var long = strings.Repeat("text ", 1000)
+	mux.Handle("GET", "/error", BufferResponse(0), func(c *Context) error {
+		c.SetHeader("ETag", `"partial"`)
+		if err := c.Write(JSON{"partial": true}); err != nil {
+			return err
+		}
+		return ErrForbidden
+	})
+	mux.Handle("GET", "/transform", BufferResponse(0), func(c *Context) error {
+		if err := c.Write("lower case"); err != nil {
+			return err
+		}
+		body, ok := c.ResponseBody()
+		if !ok || !c.SetResponseBody(bytes.ToUpper(body)) {
+			return errors.New("not buffered")
+		}
+		return nil
+	})
+	mux.Handle("GET", "/long", BufferResponse(0), Data(long, "text/plain"))
+	mux.Handle("GET", "/limit", BufferResponse(100), func(c *Context) error {
+		c.Write(long)
+		return ErrForbidden
+	})
+	mux.Handle("GET", "/unbuffered", func(c *Context) error {
+		c.Write("partial")
+		if c.ResetResponse() {
+			t.Error("unbuffered response is reset")
+		}
+		return ErrForbidden
+	})
+
+	var request = func(path string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("/error")
+	if w.Code != 403 || strings.Contains(w.Body.String(), "partial") ||
+		w.Header().Get("ETag") != "" {
+		t.Error("bad error response:", w.Code, w.Header(), w.Body.String())
+	}
+	w = request("/transform")
+	if w.Body.String() != "LOWER CASE" || w.Header().Get("Content-Length") != "10" {
+		t.Error("bad transformed response:", w.Body.String(), w.Header())
+	}
+	w = request("/long", "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" ||
+		w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()) {
+		t.Fatal("bad compressed response length:", w.Header(), w.Body.Len())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != long {
+		t.Error("bad compressed data")
+	}
+	if w = request("/limit"); w.Code != 200 || w.Body.String() != long {
+		t.Error("bad response over limit:", w.Code)
+	}
+	if w = request("/unbuffered"); w.Code != 200 || w.Body.String() != "partial" {
+		t.Error("bad unbuffered response:", w.Code, w.Body.String())
+	}
+
+	mux.BufferSize = 1 << 10
+	mux.Handle("GET", "/mux", func(c *Context) error {
+		c.Write("partial")
+		return ErrNotFound
+	})
+	if w = request("/mux"); w.Code != 404 {
+		t.Error("bad error response:", w.Code, w.Body.String())
+	}
+}
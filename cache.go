@@ -0,0 +1,453 @@
+package rest
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry is the cached response.
+type CacheEntry struct {
+	Status  int         // response status code
+	Header  http.Header // response headers
+	Body    []byte      // uncompressed response body
+	Created time.Time   // time of the response
+	Expires time.Time   // the response is fresh until this time
+	Stale   time.Time   // the stale response can be served until this time
+	Tags    []string    // tags for purging
+	Vary    []string    // request headers selecting the response variant
+}
+
+// fresh returns true if the entry is fresh at the specified time.
+func (e *CacheEntry) fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// CacheStore describes the storage of cached responses.
+type CacheStore interface {
+	// Get returns the entry with the key.
+	Get(key string) (*CacheEntry, bool)
+	// Set stores the entry with the key.
+	Set(key string, entry *CacheEntry)
+	// Delete removes the entry with the key.
+	Delete(key string)
+	// PurgeTag removes all entries with the tag and returns their number.
+	PurgeTag(tag string) int
+}
+
+// MemoryCache is the in-memory CacheStore with LRU eviction. It is safe for
+// concurrent use.
+type MemoryCache struct {
+	maxEntries int
+	mu         sync.Mutex
+	lru        *list.List
+	items      map[string]*list.Element
+	tags       map[string]map[string]struct{}
+}
+
+// memoryCacheItem is the element of LRU list.
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCache returns a new in-memory cache store with the limit of the
+// entries number. Zero means no limit.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+// Get returns the entry with the key. The entries that cannot be served even
+// as stale are removed.
+func (m *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+	var entry = elem.Value.(*memoryCacheItem).entry
+	if now := time.Now(); !entry.fresh(now) && !now.Before(entry.Stale) {
+		m.remove(elem)
+		return nil, false
+	}
+	m.lru.MoveToFront(elem)
+	return entry, true
+}
+
+// Set stores the entry with the key, evicting the least recently used entries
+// if the limit is exceeded.
+func (m *MemoryCache) Set(key string, entry *CacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+	m.items[key] = m.lru.PushFront(&memoryCacheItem{key: key, entry: entry})
+	for _, tag := range entry.Tags {
+		keys := m.tags[tag]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			m.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+}
+
+// Delete removes the entry with the key.
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.items[key]; ok {
+		m.remove(elem)
+	}
+}
+
+// PurgeTag removes all entries with the tag.
+func (m *MemoryCache) PurgeTag(tag string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys = m.tags[tag]
+	var count = len(keys)
+	for key := range keys {
+		if elem, ok := m.items[key]; ok {
+			m.remove(elem)
+		}
+	}
+	return count
+}
+
+// Len returns the number of entries.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// remove removes the element from the cache. Must be called with the lock
+// held.
+func (m *MemoryCache) remove(elem *list.Element) {
+	var item = m.lru.Remove(elem).(*memoryCacheItem)
+	delete(m.items, item.key)
+	for _, tag := range item.entry.Tags {
+		if keys := m.tags[tag]; keys != nil {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(m.tags, tag)
+			}
+		}
+	}
+}
+
+// CacheKey returns the cache key of the request: the method, host, path and
+// sorted query parameters. HEAD requests share the key with GET.
+func CacheKey(r *http.Request) string {
+	var method = r.Method
+	if method == "HEAD" {
+		method = "GET"
+	}
+	var key = method + " " + r.Host + r.URL.Path
+	if query := r.URL.Query(); len(query) > 0 {
+		key += "?" + query.Encode()
+	}
+	return key
+}
+
+// variantKey returns the key of the response variant selected by the request
+// headers.
+func variantKey(key string, vary []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// variantTag returns the implicit tag of all variants of the key.
+func variantTag(key string) string {
+	return "\x00" + key
+}
+
+// cacheTagsKey is the context data key of the cache tags.
+type cacheTagsKey struct{}
+
+// SetCacheTags sets the tags of the response used to purge it from Cache.
+func (c *Context) SetCacheTags(tags ...string) {
+	c.SetData(cacheTagsKey{}, tags)
+}
+
+// cacheableStatus contains the response status codes cached by default.
+var cacheableStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 404: true,
+	405: true, 410: true, 414: true, 501: true,
+}
+
+// Cache is the shared HTTP response cache for GET and HEAD requests.
+//
+// The responses are cached by CacheKey and the request headers listed in the
+// Vary response header. The freshness time is taken from the s-maxage or
+// max-age directives of the Cache-Control response header or from the Expires
+// header, otherwise TTL is used. The responses with Set-Cookie or Cache-Control
+// no-store, no-cache or private are not cached.
+//
+// The request Cache-Control header is honored too: no-store bypasses the cache,
+// no-cache and max-age limit the age of the served response and
+// only-if-cached returns 504 Gateway Timeout on a cache miss.
+//
+// The expired response is served while it is revalidated in the background
+// within the stale-while-revalidate period (from Cache-Control or
+// StaleWhileRevalidate).
+type Cache struct {
+	Store                CacheStore    // entries store (MemoryCache if nil)
+	TTL                  time.Duration // default freshness time (0 to cache only explicit)
+	StaleWhileRevalidate time.Duration // default stale-while-revalidate period
+	once                 sync.Once
+	mu                   sync.Mutex
+	revalidating         map[string]bool
+}
+
+// init initializes the default store.
+func (cache *Cache) init() {
+	cache.once.Do(func() {
+		if cache.Store == nil {
+			cache.Store = NewMemoryCache(1000)
+		}
+		cache.revalidating = make(map[string]bool)
+	})
+}
+
+// Handler returns the Handler caching the responses of the handlers.
+func (cache *Cache) Handler(handlers ...Handler) Handler {
+	var h = Handlers(handlers...)
+	return func(c *Context) error {
+		cache.init()
+		var method = c.Request.Method
+		if method != "GET" && method != "HEAD" {
+			return h(c)
+		}
+		var request = parseCacheControl(c.Request.Header.Get("Cache-Control"))
+		if _, ok := request["no-store"]; ok {
+			return h(c)
+		}
+		var (
+			key      = CacheKey(c.Request)
+			now      = time.Now()
+			_, only  = request["only-if-cached"]
+			_, nocc  = request["no-cache"]
+			maxAge   = -1
+			entry, _ = cache.lookup(key, c.Request)
+		)
+		if value, ok := request["max-age"]; ok {
+			maxAge, _ = strconv.Atoi(value)
+		}
+		if entry != nil && !nocc {
+			var age = int(now.Sub(entry.Created) / time.Second)
+			switch {
+			case maxAge >= 0 && age > maxAge:
+			case entry.fresh(now):
+				c.SetHeader("X-Cache", "HIT")
+				return cache.serve(c, entry, now)
+			case now.Before(entry.Stale):
+				cache.revalidate(c, h, key)
+				c.SetHeader("X-Cache", "STALE")
+				return cache.serve(c, entry, now)
+			}
+		}
+		if only {
+			return ErrGatewayTimeout
+		}
+		c.SetHeader("X-Cache", "MISS")
+		resp, err := cache.fetch(c, h, key)
+		if werr := resp.writeTo(c); err == nil {
+			err = werr
+		}
+		return err
+	}
+}
+
+// lookup returns the cached entry for the request.
+func (cache *Cache) lookup(key string, r *http.Request) (*CacheEntry, bool) {
+	entry, ok := cache.Store.Get(key)
+	if ok && len(entry.Vary) > 0 {
+		entry, ok = cache.Store.Get(variantKey(key, entry.Vary, r))
+	}
+	return entry, ok
+}
+
+// serve replies to the request with the cached entry.
+func (cache *Cache) serve(c *Context, entry *CacheEntry, now time.Time) error {
+	c.SetHeader("Age", strconv.Itoa(int(now.Sub(entry.Created)/time.Second)))
+	var resp = &recordedResponse{
+		Status: entry.Status,
+		Header: entry.Header,
+		Body:   entry.Body,
+	}
+	return resp.writeTo(c)
+}
+
+// fetch executes the handler and stores the cacheable response. The response
+// to HEAD request has no body and is not stored: HEAD is served only from the
+// entries of GET.
+func (cache *Cache) fetch(c *Context, h Handler, key string) (*recordedResponse, error) {
+	resp, sub, err := c.record(h)
+	if err == nil && c.Request.Method == "GET" {
+		tags, _ := sub.Data(cacheTagsKey{}).([]string)
+		cache.store(key, c.Request, resp, tags)
+	}
+	return resp, err
+}
+
+// revalidate executes the GET handler in the background to refresh the stale
+// entry. Only one revalidation of the key is performed at the same time.
+func (cache *Cache) revalidate(c *Context, h Handler, key string) {
+	cache.mu.Lock()
+	if cache.revalidating[key] {
+		cache.mu.Unlock()
+		return
+	}
+	cache.revalidating[key] = true
+	cache.mu.Unlock()
+
+	var background = *c
+	background.Request = c.Request.Clone(context.Background())
+	background.Request.Method = "GET" // HEAD revalidates the GET entry
+	background.data = nil
+	for key, value := range c.data {
+		background.SetData(key, value)
+	}
+	go func() {
+		defer func() {
+			cache.mu.Lock()
+			delete(cache.revalidating, key)
+			cache.mu.Unlock()
+		}()
+		cache.fetch(&background, h, key)
+	}()
+}
+
+// store saves the cacheable response to the store.
+func (cache *Cache) store(key string, r *http.Request, resp *recordedResponse, tags []string) {
+	if !cacheableStatus[resp.Status] || resp.Header.Get("Set-Cookie") != "" {
+		return
+	}
+	var control = parseCacheControl(resp.Header.Get("Cache-Control"))
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := control[directive]; ok {
+			return
+		}
+	}
+	if _, public := control["public"]; !public &&
+		r.Header.Get("Authorization") != "" {
+		return
+	}
+	var vary = headerTokens(resp.Header, "Vary")
+	for i, name := range vary {
+		if name == "*" {
+			return
+		}
+		vary[i] = http.CanonicalHeaderKey(name)
+	}
+	sort.Strings(vary)
+
+	var (
+		now     = time.Now()
+		ttl     = cache.TTL
+		stale   = cache.StaleWhileRevalidate
+		explict = false
+	)
+	if value, ok := control["s-maxage"]; ok {
+		ttl, explict = seconds(value), true
+	} else if value, ok := control["max-age"]; ok {
+		ttl, explict = seconds(value), true
+	} else if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+		ttl, explict = expires.Sub(now), true
+	}
+	if value, ok := control["stale-while-revalidate"]; ok {
+		stale = seconds(value)
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if (ttl == 0 && !explict) || ttl+stale <= 0 {
+		return
+	}
+	var entry = &CacheEntry{
+		Status:  resp.Status,
+		Header:  resp.Header,
+		Body:    resp.Body,
+		Created: now,
+		Expires: now.Add(ttl),
+		Stale:   now.Add(ttl + stale),
+		Tags:    tags,
+	}
+	if len(vary) > 0 {
+		cache.Store.Set(key, &CacheEntry{
+			Created: now,
+			Expires: entry.Expires,
+			Stale:   entry.Stale,
+			Tags:    tags,
+			Vary:    vary,
+		})
+		entry.Tags = append(append([]string(nil), tags...), variantTag(key))
+		key = variantKey(key, vary, r)
+	}
+	cache.Store.Set(key, entry)
+}
+
+// Purge removes the cached response with the key returned by CacheKey,
+// including all its variants.
+func (cache *Cache) Purge(key string) {
+	cache.init()
+	cache.Store.Delete(key)
+	cache.Store.PurgeTag(variantTag(key))
+}
+
+// PurgeTag removes all cached responses with the tag and returns their number.
+func (cache *Cache) PurgeTag(tag string) int {
+	cache.init()
+	return cache.Store.PurgeTag(tag)
+}
+
+// seconds returns the duration of the delta-seconds value.
+func seconds(value string) time.Duration {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+// parseCacheControl returns the directives of the Cache-Control header value.
+// The names of directives are lowercased.
+func parseCacheControl(header string) map[string]string {
+	var directives = make(map[string]string)
+	for _, item := range strings.Split(header, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var name, value = item, ""
+		if i := strings.Index(item, "="); i != -1 {
+			name, value = item[:i], strings.Trim(strings.TrimSpace(item[i+1:]), `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return directives
+}
@@ -0,0 +1,197 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	for _, test := range []struct {
+		method, url, key string
+	}{
+		{"GET", "http://example.com/path", "GET example.com/path"},
+		{"HEAD", "http://example.com/path", "GET example.com/path"},
+		{"GET", "http://example.com/path?b=2&a=1", "GET example.com/path?a=1&b=2"},
+	} {
+		r := httptest.NewRequest(test.method, test.url, nil)
+		if key := CacheKey(r); key != test.key {
+			t.Errorf("bad key for %s %s: %q", test.method, test.url, key)
+		}
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	var store = NewMemoryCache(2)
+	var entry = func(tags ...string) *CacheEntry {
+		return &CacheEntry{Expires: time.Now().Add(time.Minute), Tags: tags}
+	}
+	store.Set("a", entry("x"))
+	store.Set("b", entry("x", "y"))
+	store.Get("a")
+	store.Set("c", entry("y"))
+	if _, ok := store.Get("b"); ok || store.Len() != 2 {
+		t.Error("least recently used entry is not evicted")
+	}
+	if n := store.PurgeTag("y"); n != 1 {
+		t.Error("bad purged count:", n)
+	}
+	if _, ok := store.Get("a"); !ok || store.Len() != 1 {
+		t.Error("bad entries after purge")
+	}
+	store.Set("d", &CacheEntry{Expires: time.Now().Add(-time.Second)})
+	if _, ok := store.Get("d"); ok {
+		t.Error("expired entry returned")
+	}
+}
+
+func TestCache(t *testing.T) {
+	var (
+		cache = &Cache{TTL: time.Minute}
+		calls int32
+		mux   = new(ServeMux)
+	)
+	var handler = func(c *Context) error {
+		n := atomic.AddInt32(&calls, 1)
+		if c.Query("tag") != "" {
+			c.SetCacheTags(c.Query("tag"))
+		}
+		return c.Write("response " + strconv.Itoa(int(n)))
+	}
+	mux.Handle("GET", "/", cache.Handler(handler))
+	mux.Handle("HEAD", "/head", cache.Handler(handler))
+	mux.Handle("GET", "/head", cache.Handler(handler))
+	mux.Handle("GET", "/private", cache.Handler(func(c *Context) error {
+		c.SetHeader("Cache-Control", "private")
+		return handler(c)
+	}))
+	mux.Handle("GET", "/vary", cache.Handler(func(c *Context) error {
+		c.SetHeader("Vary", "Accept-Language")
+		return c.Write(c.Header("Accept-Language"))
+	}))
+	mux.Handle("GET", "/error", cache.Handler(func(c *Context) error {
+		atomic.AddInt32(&calls, 1)
+		return ErrInternalServerError
+	}))
+
+	var requestMethod = func(method, path string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	var request = func(path string, headers ...string) *httptest.ResponseRecorder {
+		return requestMethod("GET", path, headers...)
+	}
+
+	w := request("/")
+	if w.Body.String() != "response 1" || w.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("bad first response:", w.Body.String(), w.Header())
+	}
+	w = request("/", "Accept-Encoding", "gzip")
+	if w.Header().Get("X-Cache") != "HIT" || w.Header().Get("Age") == "" ||
+		w.Header().Get("Content-Type") == "" || calls != 1 {
+		t.Error("bad cached response:", w.Header(), calls)
+	}
+	if request("/", "Cache-Control", "no-cache"); calls != 2 {
+		t.Error("no-cache request served from cache")
+	}
+	if request("/", "Cache-Control", "no-store"); calls != 3 {
+		t.Error("no-store request served from cache")
+	}
+	if w = request("/other", "Cache-Control", "only-if-cached"); w.Code != 404 {
+		t.Error("bad not found status:", w.Code)
+	}
+	if w = request("/?a=1", "Cache-Control", "only-if-cached"); w.Code != http.StatusGatewayTimeout {
+		t.Error("bad only-if-cached status:", w.Code)
+	}
+
+	cache.Purge(CacheKey(httptest.NewRequest("GET", "/", nil)))
+	if request("/"); calls != 4 {
+		t.Error("purged response served from cache")
+	}
+	request("/?tag=items")
+	if n := cache.PurgeTag("items"); n != 1 {
+		t.Error("bad purged count:", n)
+	}
+
+	calls = 0
+	if w = requestMethod("HEAD", "/head"); w.Body.Len() != 0 || calls != 1 {
+		t.Error("bad head response:", w.Body.String(), calls)
+	}
+	w = request("/head")
+	if w.Body.String() != "response 2" || w.Header().Get("X-Cache") != "MISS" {
+		t.Error("head response is cached for get:", w.Body.String(), w.Header().Get("X-Cache"))
+	}
+	w = requestMethod("HEAD", "/head")
+	if w.Header().Get("X-Cache") != "HIT" || w.Body.Len() != 0 || calls != 2 {
+		t.Error("head is not served from get entry:", w.Header().Get("X-Cache"), calls)
+	}
+
+	calls = 0
+	request("/private")
+	request("/private")
+	request("/error")
+	request("/error")
+	if calls != 4 {
+		t.Error("not cacheable response is cached:", calls)
+	}
+
+	w = request("/vary", "Accept-Language", "en")
+	w = request("/vary", "Accept-Language", "ru")
+	if w.Body.String() != "ru" || w.Header().Get("X-Cache") != "MISS" {
+		t.Error("bad variant:", w.Body.String(), w.Header().Get("X-Cache"))
+	}
+	w = request("/vary", "Accept-Language", "en")
+	if w.Body.String() != "en" || w.Header().Get("X-Cache") != "HIT" {
+		t.Error("bad cached variant:", w.Body.String(), w.Header().Get("X-Cache"))
+	}
+	cache.Purge(CacheKey(httptest.NewRequest("GET", "/vary", nil)))
+	if w = request("/vary", "Accept-Language", "en"); w.Header().Get("X-Cache") != "MISS" {
+		t.Error("variant is not purged")
+	}
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	var (
+		calls int32
+		done  = make(chan struct{}, 1)
+		cache = &Cache{}
+		mux   = new(ServeMux)
+	)
+	mux.Handle("GET", "/", cache.Handler(func(c *Context) error {
+		n := atomic.AddInt32(&calls, 1)
+		c.SetHeader("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		defer func() {
+			if n > 1 {
+				done <- struct{}{}
+			}
+		}()
+		return c.Write(strconv.Itoa(int(n)))
+	}))
+	var request = func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w
+	}
+	request()
+	w := request()
+	if w.Body.String() != "1" || w.Header().Get("X-Cache") != "STALE" {
+		t.Fatal("bad stale response:", w.Body.String(), w.Header().Get("X-Cache"))
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("response is not revalidated")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if w = request(); w.Body.String() != "2" {
+		t.Error("bad revalidated response:", w.Body.String())
+	}
+}
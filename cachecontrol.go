@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheControl describes the directives of the Cache-Control response header.
+// The zero durations are omitted.
+//
+// It is used with Context.SetCacheControl or as the Handler setting the default
+// policy of the route: it is applied to successful GET and HEAD responses,
+// if the handler did not set the Cache-Control header itself:
+//
+//	var cached = rest.CacheControl{Public: true, MaxAge: time.Hour}
+//	mux.Handle("GET", "/items", cached.Handle, items)
+//
+// The default policy for all routes is set by ServeMux.CacheControl.
+type CacheControl struct {
+	MaxAge               time.Duration // max-age
+	SMaxAge              time.Duration // s-maxage for shared caches
+	StaleWhileRevalidate time.Duration // stale-while-revalidate
+	StaleIfError         time.Duration // stale-if-error
+	Public               bool          // public
+	Private              bool          // private
+	NoCache              bool          // no-cache
+	NoStore              bool          // no-store
+	MustRevalidate       bool          // must-revalidate
+	Immutable            bool          // immutable
+}
+
+// String returns the value of the Cache-Control header.
+func (cc CacheControl) String() string {
+	var directives = make([]string, 0, 4)
+	var flag = func(set bool, name string) {
+		if set {
+			directives = append(directives, name)
+		}
+	}
+	var delta = func(d time.Duration, name string) {
+		if d > 0 {
+			directives = append(directives,
+				name+"="+strconv.FormatInt(int64(d/time.Second), 10))
+		}
+	}
+	flag(cc.NoStore, "no-store")
+	flag(cc.NoCache, "no-cache")
+	flag(cc.Public, "public")
+	flag(cc.Private, "private")
+	delta(cc.MaxAge, "max-age")
+	delta(cc.SMaxAge, "s-maxage")
+	flag(cc.MustRevalidate, "must-revalidate")
+	flag(cc.Immutable, "immutable")
+	delta(cc.StaleWhileRevalidate, "stale-while-revalidate")
+	delta(cc.StaleIfError, "stale-if-error")
+	return strings.Join(directives, ", ")
+}
+
+// Handle sets the policy as the default for the route.
+func (cc CacheControl) Handle(c *Context) error {
+	c.Response.(*response).cacheControl = cc.String()
+	return nil
+}
+
+// SetCacheControl sets the Cache-Control response header.
+func (c *Context) SetCacheControl(cc CacheControl) {
+	c.SetHeader("Cache-Control", cc.String())
+}
+
+// SetExpires sets the Expires response header.
+func (c *Context) SetExpires(expires time.Time) {
+	c.SetHeader("Expires", expires.UTC().Format(http.TimeFormat))
+}
+
+// setDefaultCacheControl sets the default Cache-Control header of the
+// successful response to GET or HEAD request, if it was not set.
+func (rw *response) setDefaultCacheControl() {
+	if rw.cacheControl == "" || rw.code >= 400 ||
+		(rw.request.Method != "GET" && rw.request.Method != "HEAD") {
+		return
+	}
+	if headers := rw.Header(); headers.Get("Cache-Control") == "" {
+		headers.Set("Cache-Control", rw.cacheControl)
+	}
+}
+
+// setErrorCacheControl prevents caching of the error response.
+func (rw *response) setErrorCacheControl() {
+	var headers = rw.Header()
+	headers.Set("Cache-Control", "no-store")
+	headers.Del("Expires")
+}
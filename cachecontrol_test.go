@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheControl_String(t *testing.T) {
+	for _, test := range []struct {
+		cc     CacheControl
+		result string
+	}{
+		{CacheControl{}, ""},
+		{CacheControl{NoStore: true}, "no-store"},
+		{CacheControl{Private: true, NoCache: true}, "no-cache, private"},
+		{CacheControl{Public: true, MaxAge: time.Hour, Immutable: true},
+			"public, max-age=3600, immutable"},
+		{CacheControl{MaxAge: time.Minute, SMaxAge: 10 * time.Minute,
+			StaleWhileRevalidate: 30 * time.Second, StaleIfError: time.Hour},
+			"max-age=60, s-maxage=600, stale-while-revalidate=30, stale-if-error=3600"},
+	} {
+		if result := test.cc.String(); result != test.result {
+			t.Errorf("bad cache control: %q", result)
+		}
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	var expires = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var mux = &ServeMux{CacheControl: &CacheControl{NoCache: true}}
+	mux.Handle("GET", "/", Data("data", "text/plain"))
+	mux.Handle("GET", "/route", CacheControl{MaxAge: time.Minute}.Handle,
+		Data("data", "text/plain"))
+	mux.Handle("GET", "/own", func(c *Context) error {
+		c.SetCacheControl(CacheControl{Public: true, Immutable: true})
+		c.SetExpires(expires)
+		return c.Write("data")
+	})
+	mux.Handle("GET", "/error", func(c *Context) error {
+		c.SetCacheControl(CacheControl{Public: true, MaxAge: time.Hour})
+		c.SetExpires(expires)
+		return ErrNotFound
+	})
+	mux.Handle("POST", "/", Data("data", "text/plain"))
+
+	for _, test := range []struct {
+		method, path, cacheControl, expires string
+	}{
+		{"GET", "/", "no-cache", ""},
+		{"GET", "/route", "max-age=60", ""},
+		{"GET", "/own", "public, immutable", "Wed, 01 Jan 2020 00:00:00 GMT"},
+		{"GET", "/error", "no-store", ""},
+		{"GET", "/unknown", "no-store", ""},
+		{"POST", "/", "", ""},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Header().Get("Cache-Control") != test.cacheControl ||
+			w.Header().Get("Expires") != test.expires {
+			t.Errorf("%s %s: bad headers: %v", test.method, test.path, w.Header())
+		}
+	}
+}
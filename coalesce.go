@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// flight is the handler execution shared by concurrent identical requests.
+type flight struct {
+	done   chan struct{}
+	header http.Header // request headers of the first request
+	resp   *recordedResponse
+	err    error
+}
+
+// matches returns true if the response of the flight is selected by the
+// request headers: they are equal to the headers of the first request for all
+// names listed in the Vary response header.
+func (f *flight) matches(r *http.Request) bool {
+	for _, name := range headerTokens(f.resp.Header, "Vary") {
+		if name == "*" || strings.Join(r.Header.Values(name), ",") !=
+			strings.Join(f.header.Values(name), ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// Coalesce returns the Handler executing the handlers once for concurrent
+// identical GET and HEAD requests with the same method and CacheKey: the first
+// request executes them, and the rest wait for it and receive a copy of the
+// captured status, headers and body. It protects the backend from the burst of
+// requests when the cached response expires:
+//
+//	mux.Handle("GET", "/items", cache.Handler(rest.Coalesce(items)))
+//
+// The response is shared only with the requests having the same values of the
+// headers listed in its Vary header, other requests execute the handlers
+// themselves. The requests with Authorization or Cookie headers are not
+// coalesced, since their responses may differ for each user.
+func Coalesce(handlers ...Handler) Handler {
+	var (
+		h       = Handlers(handlers...)
+		mu      sync.Mutex
+		flights = make(map[string]*flight)
+	)
+	return func(c *Context) error {
+		var r = c.Request
+		if (r.Method != "GET" && r.Method != "HEAD") ||
+			r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+			return h(c)
+		}
+		var key = r.Method + " " + CacheKey(r) // HEAD response has no body
+		mu.Lock()
+		if f, ok := flights[key]; ok {
+			mu.Unlock()
+			select {
+			case <-f.done:
+			case <-r.Context().Done():
+				return r.Context().Err()
+			}
+			if f.resp == nil { // the handler panicked
+				return ErrInternalServerError
+			}
+			if !f.matches(r) {
+				return h(c) // another variant of the response
+			}
+			if err := f.resp.writeTo(c); err != nil {
+				return err
+			}
+			return f.err
+		}
+		var f = &flight{done: make(chan struct{}), header: r.Header.Clone()}
+		flights[key] = f
+		mu.Unlock()
+
+		func() {
+			defer func() {
+				mu.Lock()
+				delete(flights, key)
+				mu.Unlock()
+				close(f.done)
+			}()
+			f.resp, _, f.err = c.record(h)
+		}()
+		if err := f.resp.writeTo(c); err != nil {
+			return err
+		}
+		return f.err
+	}
+}
@@ -0,0 +1,147 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesce(t *testing.T) {
+	var (
+		calls   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+		mux     = new(ServeMux)
+	)
+	mux.Handle("GET", "/", Coalesce(func(c *Context) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		c.SetHeader("X-Test", "shared")
+		c.SetStatus(201)
+		return c.Write("response " + strconv.Itoa(int(atomic.LoadInt32(&calls))))
+	}))
+
+	var (
+		wg        sync.WaitGroup
+		responses = make([]*httptest.ResponseRecorder, 10)
+	)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			responses[i] = w
+		}(i)
+	}
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Error("handler is executed", calls, "times")
+	}
+	for _, w := range responses {
+		if w.Code != 201 || w.Header().Get("X-Test") != "shared" ||
+			w.Body.String() != "response 1" {
+			t.Error("bad response:", w.Code, w.Header(), w.Body.String())
+		}
+	}
+
+	// authorized requests are not coalesced
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	if mux.ServeHTTP(w, r); calls != 2 || w.Body.String() != "response 2" {
+		t.Error("bad authorized response:", calls, w.Body.String())
+	}
+}
+
+func TestCoalesce_Head(t *testing.T) {
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		mux     = new(ServeMux)
+	)
+	var handler = Coalesce(func(c *Context) error {
+		if c.Request.Method == "HEAD" {
+			close(started)
+			<-release
+		}
+		return c.Write("response")
+	})
+	mux.Handle("GET", "/", handler)
+	mux.Handle("HEAD", "/", handler)
+
+	go mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("HEAD", "/", nil))
+	<-started
+	defer close(release)
+	var done = make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		done <- w
+	}()
+	select {
+	case w := <-done:
+		if w.Body.String() != "response" {
+			t.Error("bad get response:", w.Body.String())
+		}
+	case <-time.After(time.Second):
+		t.Error("get is coalesced with head")
+	}
+}
+
+func TestCoalesce_Vary(t *testing.T) {
+	var (
+		calls   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+		mux     = new(ServeMux)
+	)
+	mux.Handle("GET", "/", Coalesce(func(c *Context) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-release
+		}
+		c.SetHeader("Vary", "Accept-Language")
+		return c.Write(c.Header("Accept-Language"))
+	}))
+	var (
+		wg        sync.WaitGroup
+		languages = []string{"en", "ru", "en"}
+		responses = make([]*httptest.ResponseRecorder, len(languages))
+	)
+	var request = func(i int) {
+		defer wg.Done()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", languages[i])
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		responses[i] = w
+	}
+	wg.Add(len(languages))
+	go request(0)
+	<-started
+	for i := 1; i < len(languages); i++ {
+		go request(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, w := range responses {
+		if w.Body.String() != languages[i] {
+			t.Errorf("bad response for %s: %q", languages[i], w.Body.String())
+		}
+	}
+	if calls != 2 {
+		t.Error("handler is executed", calls, "times")
+	}
+}
@@ -1,11 +1,239 @@
 package rest
 
 import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 )
 
-// CompressMimeTypes contains Media-Type patterns that can be compressed.
+// DefaultCompression is used as the compression level if another is not
+// specified. Compressors should interpret it as their default level.
+const DefaultCompression = -1
+
+// Compressor describes the response compression algorithm.
+//
+// If the writer returned by the NewWriter supports the Reset(io.Writer) method,
+// it is reused for subsequent responses. If it supports Flush() error, it is
+// used to flush the compressed data.
+type Compressor interface {
+	// Encoding returns the content coding name used in Accept-Encoding and
+	// Content-Encoding headers.
+	Encoding() string
+	// NewWriter returns a new writer compressing data to w with the specified
+	// level.
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// gzipCompressor implements gzip content coding.
+type gzipCompressor struct{}
+
+func (gzipCompressor) Encoding() string { return "gzip" }
+func (gzipCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, level)
+}
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// deflateCompressor implements deflate content coding, which is the zlib
+// format (RFC 1950) according to the HTTP specification.
+type deflateCompressor struct{}
+
+func (deflateCompressor) Encoding() string { return "deflate" }
+func (deflateCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, level)
+}
+
+// NewReader supports both zlib and raw deflate formats, since some clients
+// send deflate data without the zlib wrapper.
+func (deflateCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	var br = bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// zlib header: compression method 8 and the checksum
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+var (
+	compressorsMu sync.RWMutex
+	// compressors contains registered compressors in order of server
+	// preference.
+	compressors = []Compressor{gzipCompressor{}, deflateCompressor{}}
+	// compressPools contains pools of reusable compression writers.
+	compressPools sync.Map
+)
+
+// RegisterCompressor registers the compressor. The compressor with the same
+// encoding name is replaced, otherwise the new compressor gets the highest
+// server preference. Built-in compressors are gzip and deflate.
+func RegisterCompressor(compressor Compressor) {
+	var encoding = strings.ToLower(compressor.Encoding())
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	for i, c := range compressors {
+		if strings.ToLower(c.Encoding()) == encoding {
+			compressors[i] = compressor
+			// writers of the replaced compressor are not reusable
+			compressPools.Range(func(key, _ interface{}) bool {
+				if key.(compressPoolKey).encoding == encoding {
+					compressPools.Delete(key)
+				}
+				return true
+			})
+			return
+		}
+	}
+	compressors = append([]Compressor{compressor}, compressors...)
+}
+
+// Compressors returns the encoding names of the registered compressors in
+// order of server preference.
+func Compressors() []string {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	var list = make([]string, len(compressors))
+	for i, c := range compressors {
+		list[i] = c.Encoding()
+	}
+	return list
+}
+
+// getCompressor returns the registered compressor with the encoding name.
+func getCompressor(encoding string) Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	for _, c := range compressors {
+		if strings.EqualFold(c.Encoding(), encoding) {
+			return c
+		}
+	}
+	return nil
+}
+
+// acceptEncodings parses the Accept-Encoding header value and returns the
+// quality values of the content codings.
+func acceptEncodings(header string) map[string]float64 {
+	var codings = make(map[string]float64)
+	for _, item := range strings.Split(header, ",") {
+		var params []string
+		if i := strings.Index(item, ";"); i != -1 {
+			params = strings.Split(item[i+1:], ";")
+			item = item[:i]
+		}
+		var coding = strings.ToLower(strings.TrimSpace(item))
+		if coding == "" {
+			continue
+		}
+		var q = 1.0
+		for _, param := range params {
+			param = strings.TrimSpace(param)
+			if len(param) < 2 || (param[0] != 'q' && param[0] != 'Q') ||
+				param[1] != '=' {
+				continue
+			}
+			value, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil || value < 0 || value > 1 {
+				value = 0
+			}
+			q = value
+		}
+		if coding == "x-gzip" {
+			coding = "gzip"
+		}
+		codings[coding] = q
+	}
+	return codings
+}
+
+// negotiateCompressor returns the compressor of the content coding most
+// preferred by the Accept-Encoding header value. On equal quality values the
+// server preference of the compressors is used. Returns nil if no compression
+// is acceptable or identity is preferred.
+func negotiateCompressor(header string, list []Compressor) Compressor {
+	if list = acceptableCompressors(header, list); len(list) > 0 {
+		return list[0]
+	}
+	return nil
+}
+
+// acceptableCompressors returns the compressors acceptable by the
+// Accept-Encoding header value and more preferred than identity, ordered by
+// the client and then by the server preference.
+func acceptableCompressors(header string, list []Compressor) []Compressor {
+	if header == "" {
+		return nil
+	}
+	var (
+		codings          = acceptEncodings(header)
+		identity, strict = codings["identity"]
+		result           = make([]Compressor, 0, len(list))
+		qualities        = make([]float64, 0, len(list))
+	)
+	for _, compressor := range list {
+		q, ok := codings[strings.ToLower(compressor.Encoding())]
+		if !ok {
+			q = codings["*"]
+		}
+		if q <= 0 || (strict && q < identity) {
+			continue
+		}
+		// insert keeping the order by quality
+		var i = len(result)
+		for i > 0 && qualities[i-1] < q {
+			i--
+		}
+		result = append(result[:i], append([]Compressor{compressor}, result[i:]...)...)
+		qualities = append(qualities[:i], append([]float64{q}, qualities[i:]...)...)
+	}
+	return result
+}
+
+// compressPoolKey is the key of the compression writers pool.
+type compressPoolKey struct {
+	encoding string
+	level    int
+}
+
+// compressWriter returns the compression writer from the pool or creates the
+// new one.
+func compressWriter(compressor Compressor, w io.Writer, level int) (io.WriteCloser, error) {
+	var key = compressPoolKey{strings.ToLower(compressor.Encoding()), level}
+	if pool, ok := compressPools.Load(key); ok {
+		if zw, ok := pool.(*sync.Pool).Get().(io.WriteCloser); ok {
+			zw.(interface{ Reset(io.Writer) }).Reset(w)
+			return zw, nil
+		}
+	}
+	return compressor.NewWriter(w, level)
+}
+
+// releaseCompressWriter closes the compression writer and returns it to the
+// pool if it can be reused.
+func releaseCompressWriter(compressor Compressor, zw io.WriteCloser, level int) error {
+	var err = zw.Close()
+	if _, ok := zw.(interface{ Reset(io.Writer) }); ok {
+		var key = compressPoolKey{strings.ToLower(compressor.Encoding()), level}
+		pool, _ := compressPools.LoadOrStore(key, new(sync.Pool))
+		pool.(*sync.Pool).Put(zw)
+	}
+	return err
+}
+
+// CompressMimeTypes contains Media-Type patterns that can be compressed. It is
+// used by the CompressionPolicy without its own MimeTypes.
+//
+// Use AddCompressMimeType to change it while serving requests.
 var CompressMimeTypes = map[string][]string{
 	"text":        {"*"},
 	"application": {"json", "*+json", "xml", "*+xml", "javascript", "x-javascript", "x-font-ttf"},
@@ -14,19 +242,28 @@ var CompressMimeTypes = map[string][]string{
 	"font":        {"eot", "opentype"},
 }
 
+// compressMimeTypesMu protects CompressMimeTypes.
+var compressMimeTypesMu sync.RWMutex
+
 // AddCompressMimeType registers a new type for compression.
 func AddCompressMimeType(maintype, subtypePattern string) {
-	patterns := CompressMimeTypes[maintype]
-	if patterns == nil {
-		patterns = make([]string, 0, 1)
-	}
-	patterns = append(patterns, subtypePattern)
-	CompressMimeTypes[maintype] = patterns
+	compressMimeTypesMu.Lock()
+	CompressMimeTypes[maintype] = append(CompressMimeTypes[maintype],
+		subtypePattern)
+	compressMimeTypesMu.Unlock()
 }
 
 // isCompress returns true if contentType falls under the definition of patterns
 // for supporting compression of data types.
 func isCompress(contentType string) bool {
+	compressMimeTypesMu.RLock()
+	defer compressMimeTypesMu.RUnlock()
+	return matchMimeType(CompressMimeTypes, contentType)
+}
+
+// matchMimeType returns true if contentType falls under the definition of
+// patterns.
+func matchMimeType(patterns map[string][]string, contentType string) bool {
 	i := strings.Index(contentType, ";")
 	if i == -1 {
 		i = len(contentType)
@@ -37,7 +274,7 @@ func isCompress(contentType string) bool {
 		return false
 	}
 	var subtype = contentType[i+1:]
-	for _, pattern := range CompressMimeTypes[contentType[:i]] {
+	for _, pattern := range patterns[contentType[:i]] {
 		ok, err := path.Match(pattern, subtype)
 		if err != nil {
 			continue
@@ -48,3 +285,74 @@ func isCompress(contentType string) bool {
 	}
 	return false
 }
+
+// CompressionPolicy describes the rules of the response compression. The zero
+// value compresses the data types from CompressMimeTypes with all registered
+// compressors.
+//
+// The policy should not be changed after it is used by ServeMux.
+type CompressionPolicy struct {
+	MimeTypes map[string][]string // compressed Media-Type patterns
+	MinSize   int                 // minimum response size for compression
+	Level     int                 // compression level (default if zero)
+	Encodings []string            // allowed encodings in order of preference
+	Disabled  bool                // disable response compression
+}
+
+// AddMimeType adds a new type for compression to the policy. If the policy has
+// no own types, they are copied from CompressMimeTypes.
+func (p *CompressionPolicy) AddMimeType(maintype, subtypePattern string) {
+	if p.MimeTypes == nil {
+		compressMimeTypesMu.RLock()
+		p.MimeTypes = make(map[string][]string, len(CompressMimeTypes)+1)
+		for key, patterns := range CompressMimeTypes {
+			p.MimeTypes[key] = append([]string(nil), patterns...)
+		}
+		compressMimeTypesMu.RUnlock()
+	}
+	p.MimeTypes[maintype] = append(p.MimeTypes[maintype], subtypePattern)
+}
+
+// compressible returns true if the policy allows compression of the content
+// type.
+func (p *CompressionPolicy) compressible(contentType string) bool {
+	if p == nil || p.MimeTypes == nil {
+		return isCompress(contentType)
+	}
+	return matchMimeType(p.MimeTypes, contentType)
+}
+
+// level returns the compression level.
+func (p *CompressionPolicy) level() int {
+	if p == nil || p.Level == 0 {
+		return DefaultCompression
+	}
+	return p.Level
+}
+
+// negotiate returns the compressor allowed by the policy and most preferred by
+// the Accept-Encoding header value.
+func (p *CompressionPolicy) negotiate(header string) Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	if p == nil || len(p.Encodings) == 0 {
+		return negotiateCompressor(header, compressors)
+	}
+	var list = make([]Compressor, 0, len(p.Encodings))
+	for _, encoding := range p.Encodings {
+		for _, c := range compressors {
+			if strings.EqualFold(c.Encoding(), encoding) {
+				list = append(list, c)
+				break
+			}
+		}
+	}
+	return negotiateCompressor(header, list)
+}
+
+// NoCompression is the Handler disabling the response compression. It is used
+// before other route handlers.
+func NoCompression(c *Context) error {
+	c.DisableCompression()
+	return nil
+}
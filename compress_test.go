@@ -1,6 +1,14 @@
 package rest
 
-import "testing"
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
 
 func TestCompress(t *testing.T) {
 	for _, data := range []struct {
@@ -59,3 +67,144 @@ func TestCompress(t *testing.T) {
 		t.Error("bad registering new mime-type pattern")
 	}
 }
+
+func TestNegotiateCompressor(t *testing.T) {
+	var list = []Compressor{gzipCompressor{}, deflateCompressor{}}
+	for _, data := range []struct {
+		header   string
+		encoding string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"x-gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"gzip;q=0, deflate;q=0.1", "deflate"},
+		{"gzip;q=0", ""},
+		{"*", "gzip"},
+		{"*;q=0.5, gzip;q=0", "deflate"},
+		{"br", ""},
+		{"gzip;q=0.5, identity", ""},
+		{"gzip, identity;q=0.5", "gzip"},
+		{"GZIP; Q=0.8, deflate; q=0.9", "deflate"},
+		{"gzip;q=bad, deflate;q=0.1", "deflate"},
+	} {
+		var encoding string
+		if compressor := negotiateCompressor(data.header, list); compressor != nil {
+			encoding = compressor.Encoding()
+		}
+		if encoding != data.encoding {
+			t.Errorf("bad negotiation for %q: %q", data.header, encoding)
+		}
+	}
+}
+
+type testCompressor struct{}
+
+func (testCompressor) Encoding() string { return "test" }
+func (testCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestRegisterCompressor(t *testing.T) {
+	RegisterCompressor(testCompressor{})
+	defer func() {
+		compressorsMu.Lock()
+		compressors = compressors[1:]
+		compressorsMu.Unlock()
+	}()
+	if list := Compressors(); len(list) != 3 || list[0] != "test" {
+		t.Error("bad compressors list:", list)
+	}
+	RegisterCompressor(testCompressor{})
+	if list := Compressors(); len(list) != 3 {
+		t.Error("bad compressors list:", list)
+	}
+	if getCompressor("TEST") == nil || getCompressor("unknown") != nil {
+		t.Error("bad compressor lookup")
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip, test")
+	w := httptest.NewRecorder()
+	Data("<html>test</html>", "text/html").ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "test" {
+		t.Error("bad content encoding:", w.Header().Get("Content-Encoding"))
+	}
+	if w.Body.String() != "<html>test</html>" {
+		t.Error("bad body:", w.Body.String())
+	}
+}
+
+func TestDeflateResponse(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip;q=0.5, deflate")
+	w := httptest.NewRecorder()
+	Data("<html>test</html>", "text/html").ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatal("bad content encoding:", w.Header().Get("Content-Encoding"))
+	}
+	zr, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil || string(data) != "<html>test</html>" {
+		t.Error("bad body:", string(data), err)
+	}
+}
+
+func TestCompressionPolicy(t *testing.T) {
+	var policy = &CompressionPolicy{
+		MinSize:   10,
+		Level:     gzip.BestSpeed,
+		Encodings: []string{"deflate", "unknown"},
+	}
+	policy.AddMimeType("policy", "*pattern")
+	if !policy.compressible("policy/x-pattern") || isCompress("policy/x-pattern") {
+		t.Error("bad policy mime-type pattern")
+	}
+	if !policy.compressible("text/plain") {
+		t.Error("bad copy of default mime-types")
+	}
+
+	var mux = &ServeMux{Compression: policy}
+	mux.Handle("GET", "/:size", func(c *Context) error {
+		size, _ := strconv.Atoi(c.Param("size"))
+		return c.Write(strings.Repeat("x", size))
+	})
+	mux.Handle("GET", "/disabled/:size", NoCompression, func(c *Context) error {
+		size, _ := strconv.Atoi(c.Param("size"))
+		return c.Write(strings.Repeat("x", size))
+	})
+	for _, data := range []struct {
+		path     string
+		encoding string
+	}{
+		{"/5", ""},
+		{"/100", "deflate"},
+		{"/disabled/100", ""},
+	} {
+		r := httptest.NewRequest("GET", data.path, nil)
+		r.Header.Set("Accept-Encoding", "gzip, deflate")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if encoding := w.Header().Get("Content-Encoding"); encoding != data.encoding {
+			t.Errorf("bad encoding for %s: %q", data.path, encoding)
+		}
+	}
+
+	policy.Disabled = true
+	r := httptest.NewRequest("GET", "/100", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Error("bad encoding for disabled policy:", encoding)
+	}
+}
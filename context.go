@@ -1,6 +1,8 @@
 package rest
 
 import (
+	"context"
+	"errors"
 	"io"
 	"mime/multipart"
 	"net"
@@ -9,7 +11,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/mdigger/log"
 	"github.com/mdigger/router"
 )
 
@@ -20,9 +21,14 @@ type Context struct {
 	Encoder       Encoder                     // data encoder
 	AllowMultiple bool                        // allow multiple response
 	params        router.Params               // path named params
+	route         string                      // matched route pattern
 	data          map[interface{}]interface{} // request context data
 	query         url.Values                  // url query values
-	logFields     []log.Field                 // additional log fields
+	logFields     []interface{}               // additional log fields
+	logger        Logger                      // ServeMux logger
+	reqID         string                      // request ID
+	span          *Span                       // request trace span
+	checkOrigin   OriginChecker               // WebSocket origin check
 }
 
 // newContext return new initialized request context.
@@ -74,6 +80,12 @@ func (c *Context) Compressed() bool {
 	return c.Response.(*response).compressed
 }
 
+// DisableCompression disables the compression of the response. It has no
+// effect if the response has already been written.
+func (c *Context) DisableCompression() {
+	c.Response.(*response).noCompress = true
+}
+
 // ContentLength returns the uncompressed size of the response data.
 func (c *Context) ContentLength() int64 {
 	return c.Response.(*response).written
@@ -169,16 +181,19 @@ func (c *Context) Write(data interface{}) (err error) {
 		err = encoder(c, data)
 	case error:
 		var code = http.StatusInternalServerError
-		if httperror, ok := data.(*Error); ok {
+		if httperror := (*Error)(nil); errors.As(data, &httperror) {
 			code = httperror.Code
 		} else if os.IsNotExist(data) {
 			code = http.StatusNotFound
 		} else if os.IsPermission(data) {
 			code = http.StatusForbidden
+		} else if errors.Is(data, context.DeadlineExceeded) {
+			code = http.StatusGatewayTimeout
 		} else if timeout, ok := data.(net.Error); ok && timeout.Timeout() {
 			code = http.StatusRequestTimeout
 		}
 		c.SetStatus(code)
+		c.Response.(*response).setErrorCacheControl()
 		err = encoder(c, data)
 	default:
 		err = encoder(c, data)
@@ -248,6 +263,11 @@ func (c *Context) Params() []string {
 	return list
 }
 
+// Route returns the path pattern of the route matched by ServeMux.
+func (c *Context) Route() string {
+	return c.route
+}
+
 // Data returns the user data stored in the request context with specified key.
 // Usually this information is used when you want to pass them between multiple
 // processors.
@@ -270,9 +290,56 @@ func (c *Context) SetData(key, value interface{}) {
 	c.data[key] = value
 }
 
+// Deadline implements context.Context interface and returns the deadline of
+// the request context.
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	return c.Request.Context().Deadline()
+}
+
+// Done implements context.Context interface and returns the channel closed
+// when the request is canceled or times out.
+func (c *Context) Done() <-chan struct{} {
+	return c.Request.Context().Done()
+}
+
+// Err implements context.Context interface and returns the reason of the
+// request context cancellation.
+func (c *Context) Err() error {
+	return c.Request.Context().Err()
+}
+
+// Value implements context.Context interface. It returns the data saved with
+// SetData or, if not found, the value of the request context. So the Context
+// can be passed to the functions accepting context.Context.
+func (c *Context) Value(key interface{}) interface{} {
+	if value, ok := c.data[key]; ok {
+		return value
+	}
+	return c.Request.Context().Value(key)
+}
+
+// WithValue adds the value to the request context.
+func (c *Context) WithValue(key, value interface{}) {
+	c.setRequestContext(context.WithValue(c.Request.Context(), key, value))
+}
+
+// WithTimeout sets the timeout of the request context. The returned function
+// should be called to release resources.
+func (c *Context) WithTimeout(timeout time.Duration) context.CancelFunc {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	c.setRequestContext(ctx)
+	return cancel
+}
+
+// setRequestContext replaces the request with a copy with the context.
+func (c *Context) setRequestContext(ctx context.Context) {
+	c.Request = c.Request.WithContext(ctx)
+	c.Response.(*response).request = c.Request
+}
+
 // AddLogField add named filed to context log.
 func (c *Context) AddLogField(key string, value interface{}) {
-	c.logFields = append(c.logFields, log.Field{Name: key, Value: value})
+	c.logFields = append(c.logFields, key, value)
 }
 
 // RealIP returns a real IP address from headers. To this end, we use the
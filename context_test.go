@@ -1,12 +1,14 @@
 package rest
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestContext(t *testing.T) {
@@ -150,3 +152,47 @@ func TestContext_ServeFile(t *testing.T) {
 		t.Error("multiply responses")
 	}
 }
+
+func TestContext_Context(t *testing.T) {
+	type key string
+	r := httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), key("request"), "request value"))
+	var c = newContext(httptest.NewRecorder(), r)
+	var ctx context.Context = c
+	c.SetData(key("data"), "data value")
+	c.WithValue(key("value"), "context value")
+	for name, value := range map[key]interface{}{
+		"request": "request value",
+		"data":    "data value",
+		"value":   "context value",
+		"unknown": nil,
+	} {
+		if v := ctx.Value(name); v != value {
+			t.Errorf("bad %s value: %v", name, v)
+		}
+	}
+	if c.Request.Context().Value(key("value")) != "context value" ||
+		c.Response.(*response).request != c.Request {
+		t.Error("request is not updated")
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("unexpected deadline")
+	}
+
+	cancel := c.WithTimeout(time.Millisecond)
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("deadline is not set")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context is not done")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Error("bad context error:", ctx.Err())
+	}
+	if ctx.Value(key("request")) != "request value" {
+		t.Error("request value is lost")
+	}
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxDecompressedSize is used as the limit of the decompressed request
+// body size if another is not specified.
+const DefaultMaxDecompressedSize = 32 << 20
+
+// Decompressor is implemented by Compressors that can decode the request body
+// with their content coding.
+type Decompressor interface {
+	// NewReader returns a new reader decompressing data from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// decompressBody replaces the request body with the decompressed one according
+// to the Content-Encoding header. The size of the decompressed data is limited
+// to protect against decompression bombs: reading beyond the limit returns
+// ErrRequestEntityTooLarge.
+//
+// Returns ErrUnsupportedMediaType if the content coding is not supported.
+func decompressBody(r *http.Request, limit int64) error {
+	var encodings = headerTokens(r.Header, "Content-Encoding")
+	if len(encodings) == 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	var (
+		body    io.Reader = r.Body
+		closers           = []io.Closer{r.Body}
+	)
+	// content codings are listed in the order they were applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var encoding = strings.ToLower(encodings[i])
+		switch encoding {
+		case "identity":
+			continue
+		case "x-gzip":
+			encoding = "gzip"
+		}
+		decompressor, ok := getCompressor(encoding).(Decompressor)
+		if !ok {
+			return ErrUnsupportedMediaType
+		}
+		reader, err := decompressor.NewReader(body)
+		if err != nil {
+			return ErrBadRequest
+		}
+		body = reader
+		closers = append(closers, reader)
+	}
+	r.Body = &decompressedBody{
+		reader:  body,
+		limit:   limit,
+		closers: closers,
+	}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// decompressors returns the list of content codings supported for request
+// body decoding.
+func decompressors() []string {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	var list = make([]string, 0, len(compressors))
+	for _, c := range compressors {
+		if _, ok := c.(Decompressor); ok {
+			list = append(list, c.Encoding())
+		}
+	}
+	return list
+}
+
+// decompressedBody is the decompressed request body with the size limit.
+type decompressedBody struct {
+	reader  io.Reader
+	limit   int64
+	read    int64
+	closers []io.Closer
+}
+
+// Read reads the decompressed data.
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.read > b.limit {
+		return 0, ErrRequestEntityTooLarge
+	}
+	if remain := b.limit - b.read + 1; int64(len(p)) > remain {
+		p = p[:remain] // read one byte more to detect overflow
+	}
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		return n - int(b.read-b.limit), ErrRequestEntityTooLarge
+	}
+	return n, err
+}
+
+// Close closes the decompression readers and the original body.
+func (b *decompressedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if e := b.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecompressBody(t *testing.T) {
+	var data = strings.Repeat(`{"test": "value"}`, 10)
+	var gzipped, zlibbed, deflated bytes.Buffer
+	gzw := gzip.NewWriter(&gzipped)
+	gzw.Write([]byte(data))
+	gzw.Close()
+	zw := zlib.NewWriter(&zlibbed)
+	zw.Write([]byte(data))
+	zw.Close()
+	fw, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	fw.Write([]byte(data))
+	fw.Close()
+
+	for _, test := range []struct {
+		encoding string
+		body     []byte
+		limit    int64
+		err      error
+		readErr  error
+	}{
+		{"", []byte(data), 1000, nil, nil},
+		{"gzip", gzipped.Bytes(), 1000, nil, nil},
+		{"x-gzip", gzipped.Bytes(), 1000, nil, nil},
+		{"deflate", zlibbed.Bytes(), 1000, nil, nil},
+		{"deflate", deflated.Bytes(), 1000, nil, nil},
+		{"identity, gzip", gzipped.Bytes(), 1000, nil, nil},
+		{"gzip", gzipped.Bytes(), 100, nil, ErrRequestEntityTooLarge},
+		{"gzip", []byte("bad data"), 1000, ErrBadRequest, nil},
+		{"br", []byte(data), 1000, ErrUnsupportedMediaType, nil},
+	} {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(test.body))
+		r.Header.Set("Content-Encoding", test.encoding)
+		if err := decompressBody(r, test.limit); err != test.err {
+			t.Errorf("%s: bad error: %v", test.encoding, err)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+		result, err := io.ReadAll(r.Body)
+		if err != test.readErr {
+			t.Errorf("%s: bad read error: %v", test.encoding, err)
+		}
+		if test.readErr == nil && string(result) != data {
+			t.Errorf("%s: bad data: %q", test.encoding, result)
+		}
+		if test.readErr != nil && int64(len(result)) != test.limit {
+			t.Errorf("%s: bad limited size: %d", test.encoding, len(result))
+		}
+		if r.Body.Close() != nil {
+			t.Error("close error")
+		}
+	}
+}
+
+func TestServeMuxDecompress(t *testing.T) {
+	var mux = &ServeMux{MaxDecompressedSize: 100}
+	mux.Handle("POST", "/", func(c *Context) error {
+		var v = new(struct {
+			Test string `json:"test"`
+		})
+		if err := c.Bind(v); err != nil {
+			return err
+		}
+		return c.Write(v.Test)
+	})
+	var compress = func(data string) io.Reader {
+		var buf bytes.Buffer
+		gzw := gzip.NewWriter(&buf)
+		gzw.Write([]byte(data))
+		gzw.Close()
+		return &buf
+	}
+
+	r := httptest.NewRequest("POST", "/", compress(`{"test": "value"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "value" {
+		t.Error("bad response:", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("POST", "/", compress(`{"test": "`+
+		strings.Repeat("x", 200)+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Error("bad status for decompression bomb:", w.Code)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"test": "value"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "compress")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType ||
+		w.Header().Get("Accept-Encoding") != "gzip, deflate" {
+		t.Error("bad response for unsupported encoding:", w.Code,
+			w.Header().Get("Accept-Encoding"))
+	}
+}
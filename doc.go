@@ -6,4 +6,13 @@
 //	- separate support for handling redirects
 // 	- ready for logging responses
 // 	- support for named parameters in the path
+// 	- server-sent events broker with topic subscriptions
+// 	- built-in WebSocket connections
+// 	- static files from any fs.FS with precompressed files and SPA fallback
+// 	- pluggable response compression and request decompression (gzip and deflate
+// 	  are built-in)
+// 	- in-memory response cache with tags and stale-while-revalidate
+// 	- access log in Common, Combined, JSON or custom formats with file rotation
+// 	- request IDs and W3C Trace Context propagation
+// 	- Prometheus metrics without external dependencies
 package rest
@@ -12,9 +12,11 @@ func defaultEncoder(c *Context, v interface{}) error {
 	enc.SetIndent("", "    ")
 	if err, ok := v.(error); ok {
 		return enc.Encode(&struct {
-			Error string `json:"error,omitempty"`
+			Error     string `json:"error,omitempty"`
+			RequestID string `json:"request_id,omitempty"`
 		}{
-			Error: err.Error(),
+			Error:     err.Error(),
+			RequestID: c.RequestID(),
 		})
 	}
 	return enc.Encode(v)
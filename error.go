@@ -13,12 +13,15 @@ var (
 	ErrNotFound              = &Error{404, "not found"}
 	ErrMethodNotAllowed      = &Error{405, "method not allowed"}
 	ErrLengthRequired        = &Error{411, "length required"}
+	ErrPreconditionFailed    = &Error{412, "precondition failed"}
 	ErrRequestEntityTooLarge = &Error{413, "request entity too large"}
 	ErrUnsupportedMediaType  = &Error{415, "unsupported media type"}
+	ErrPreconditionRequired  = &Error{428, "precondition required"}
 	ErrInternalServerError   = &Error{500, "internal server error"}
 	ErrMultipleResponse      = &Error{500, "multiple server response"}
 	ErrNotImplemented        = &Error{501, "not implemented"}
 	ErrServiceUnavailable    = &Error{503, "service unavailable"}
+	ErrGatewayTimeout        = &Error{504, "gateway timeout"}
 )
 
 // Error describes the status and text message to send to a HTTP request.
@@ -0,0 +1,175 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AutoETag is the Handler enabling automatic ETag generation for the response.
+// It is used before other route handlers. The same can be enabled for all
+// routes with ServeMux.AutoETag.
+//
+// The successful response to GET or HEAD request is buffered until the
+// handler returns, and its strong ETag is calculated from the content, unless
+// the handler set the ETag itself. If the request has a matching If-None-Match
+// header (or If-Modified-Since with Last-Modified), 304 Not Modified is sent
+// without the body.
+func AutoETag(c *Context) error {
+	c.Response.(*response).autoETag = true
+	return nil
+}
+
+// SetETag sets the ETag response header. The value is quoted, if necessary.
+// Use the "W/" prefix for the weak ETag.
+func (c *Context) SetETag(etag string) {
+	c.SetHeader("ETag", quoteETag(etag))
+}
+
+// quoteETag returns the quoted entity tag.
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	if strings.HasPrefix(etag, "W/") {
+		return `W/"` + etag[2:] + `"`
+	}
+	return `"` + etag + `"`
+}
+
+// SetLastModified sets the Last-Modified response header.
+func (c *Context) SetLastModified(modtime time.Time) {
+	if !isZeroTime(modtime) {
+		c.SetHeader("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+}
+
+// NotModified evaluates the If-None-Match and If-Modified-Since request
+// headers against the ETag and Last-Modified response headers set by the
+// handler. If the resource is not modified, it replies with 304 Not Modified
+// and returns true. It allows to skip the rendering when the version of the
+// resource is known:
+//
+//	c.SetETag(item.Version)
+//	if c.NotModified() {
+//		return nil
+//	}
+//	return c.Write(item)
+func (c *Context) NotModified() bool {
+	if c.IsWrote() || c.Status() != http.StatusOK ||
+		!notModified(c.Request, c.Response.Header()) {
+		return false
+	}
+	c.Response.(*response).writeNotModified()
+	return true
+}
+
+// CheckPreconditions evaluates the If-Match, If-Unmodified-Since and
+// If-None-Match request headers against the current version of the resource
+// according to RFC 7232 for state-changing requests (PUT, PATCH, DELETE, ...).
+// The etag is quoted as in SetETag. The empty etag and zero modtime mean the
+// resource does not exist, so "If-None-Match: *" allows only its creation.
+//
+// Returns ErrPreconditionFailed if the condition is false. If required is true
+// and the request has no conditional headers, returns ErrPreconditionRequired
+// (RFC 6585) to prevent lost updates. The GET and HEAD requests are always
+// allowed: use NotModified for them.
+func (c *Context) CheckPreconditions(etag string, modtime time.Time, required bool) error {
+	if method := c.Request.Method; method == "GET" || method == "HEAD" {
+		return nil
+	}
+	etag = quoteETag(etag)
+	var (
+		exists            = etag != "" || !isZeroTime(modtime)
+		ifMatch           = c.Header("If-Match")
+		ifUnmodifiedSince = c.Header("If-Unmodified-Since")
+		ifNoneMatch       = c.Header("If-None-Match")
+	)
+	if required && ifMatch == "" && ifUnmodifiedSince == "" && ifNoneMatch == "" {
+		return ErrPreconditionRequired
+	}
+	if ifMatch != "" {
+		if !exists || (strings.TrimSpace(ifMatch) != "*" &&
+			!etagMatch(ifMatch, etag, false)) {
+			return ErrPreconditionFailed
+		}
+	} else if ifUnmodifiedSince != "" {
+		date, err := http.ParseTime(ifUnmodifiedSince)
+		if err == nil && (!exists ||
+			(!isZeroTime(modtime) && modtime.Truncate(time.Second).After(date))) {
+			return ErrPreconditionFailed
+		}
+	}
+	if ifNoneMatch != "" && exists {
+		if strings.TrimSpace(ifNoneMatch) == "*" ||
+			etagMatch(ifNoneMatch, etag, true) {
+			return ErrPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// notModified returns true if the conditional GET or HEAD request matches the
+// ETag or Last-Modified response headers. If-None-Match takes precedence over
+// If-Modified-Since.
+func notModified(r *http.Request, header http.Header) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatch(ifNoneMatch, header.Get("ETag"), true)
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(ifModifiedSince)
+}
+
+// etagMatch returns true if the list of entity tags from If-Match or
+// If-None-Match header contains the etag or is "*". The weak comparison
+// ignores the weakness indicator, the strong one requires both tags to be
+// strong.
+func etagMatch(list, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for {
+		list = strings.TrimLeft(list, " \t,")
+		if list == "" {
+			return false
+		}
+		var isWeak = strings.HasPrefix(list, "W/")
+		if isWeak {
+			list = list[2:]
+		}
+		if list == "" || list[0] != '"' {
+			return false // bad format
+		}
+		var end = strings.IndexByte(list[1:], '"')
+		if end == -1 {
+			return false
+		}
+		var tag = list[:end+2]
+		list = list[end+2:]
+		if tag == etag && (weak || !isWeak) {
+			return true
+		}
+	}
+}
+
+// isZeroTime returns true if the time is zero or the Unix epoch.
+func isZeroTime(t time.Time) bool {
+	return t.IsZero() || t.Equal(time.Unix(0, 0))
+}
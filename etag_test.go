@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEtagMatch(t *testing.T) {
+	for _, test := range []struct {
+		list, etag   string
+		weak, result bool
+	}{
+		{`"abc"`, `"abc"`, false, true},
+		{`"abc"`, `"abd"`, true, false},
+		{`*`, `"abc"`, false, true},
+		{`*`, ``, false, false},
+		{`"a", "b,c", "d"`, `"b,c"`, false, true},
+		{`W/"abc"`, `"abc"`, true, true},
+		{`W/"abc"`, `"abc"`, false, false},
+		{`"abc"`, `W/"abc"`, true, true},
+		{`"abc"`, `W/"abc"`, false, false},
+		{`abc`, `"abc"`, true, false},
+		{`"abc`, `"abc"`, true, false},
+	} {
+		if etagMatch(test.list, test.etag, test.weak) != test.result {
+			t.Errorf("bad match %s with %s (weak %v)", test.list, test.etag, test.weak)
+		}
+	}
+}
+
+func TestAutoETag(t *testing.T) {
+	var mux = &ServeMux{AutoETag: true}
+	mux.Handle("GET", "/", func(c *Context) error {
+		return c.Write(JSON{"test": strings.Repeat("value", 100)})
+	})
+	mux.Handle("GET", "/error", func(c *Context) error {
+		return ErrNotFound
+	})
+	mux.Handle("GET", "/known", func(c *Context) error {
+		c.SetETag("v1")
+		c.SetLastModified(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		if c.NotModified() {
+			return nil
+		}
+		return c.Write("rendered")
+	})
+
+	var request = func(path string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("/")
+	var etag = w.Header().Get("ETag")
+	if w.Code != 200 || !strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, "W/") {
+		t.Fatal("bad etag:", w.Code, etag)
+	}
+	if w.Header().Get("Content-Length") != "519" {
+		t.Error("bad content length:", w.Header().Get("Content-Length"))
+	}
+	w = request("/", "If-None-Match", `"other", `+etag)
+	if w.Code != 304 || w.Body.Len() != 0 || w.Header().Get("ETag") != etag ||
+		w.Header().Get("Content-Type") != "" {
+		t.Error("bad not modified response:", w.Code, w.Header())
+	}
+
+	w = request("/", "Accept-Encoding", "gzip")
+	var gzipETag = w.Header().Get("ETag")
+	if gzipETag == etag || !strings.HasSuffix(gzipETag, `-gzip"`) {
+		t.Error("bad etag of compressed response:", gzipETag)
+	}
+	w = request("/", "Accept-Encoding", "gzip", "If-None-Match", gzipETag)
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Error("bad not modified compressed response:", w.Code, w.Body.Len())
+	}
+
+	w = request("/error", "If-None-Match", "*")
+	if w.Code != 404 || w.Header().Get("ETag") != "" {
+		t.Error("etag for error:", w.Code, w.Header().Get("ETag"))
+	}
+
+	w = request("/known")
+	if w.Code != 200 || w.Header().Get("ETag") != `"v1"` || w.Body.String() != "rendered" {
+		t.Error("bad known etag response:", w.Code, w.Header().Get("ETag"))
+	}
+	w = request("/known", "If-None-Match", `W/"v1"`)
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Error("bad not modified known etag:", w.Code)
+	}
+	w = request("/known", "If-Modified-Since", "Wed, 01 Jan 2020 00:00:00 GMT")
+	if w.Code != 304 {
+		t.Error("bad if-modified-since response:", w.Code)
+	}
+	w = request("/known", "If-Modified-Since", "Tue, 31 Dec 2019 00:00:00 GMT")
+	if w.Code != 200 {
+		t.Error("bad modified response:", w.Code)
+	}
+
+	// per route
+	mux = new(ServeMux)
+	mux.Handle("GET", "/", AutoETag, Data("data", "text/plain"))
+	w = request("/")
+	if w.Header().Get("ETag") == "" {
+		t.Error("etag is not generated per route")
+	}
+	r := httptest.NewRequest("POST", "/", nil)
+	w = httptest.NewRecorder()
+	if mux.ServeHTTP(w, r); w.Code != http.StatusMethodNotAllowed {
+		t.Error("bad status:", w.Code)
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	var modtime = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		method   string
+		headers  []string
+		etag     string
+		modtime  time.Time
+		required bool
+		err      error
+	}{
+		{"PUT", nil, "v1", modtime, false, nil},
+		{"PUT", nil, "v1", modtime, true, ErrPreconditionRequired},
+		{"GET", nil, "v1", modtime, true, nil},
+		{"PUT", []string{"If-Match", `"v1"`}, "v1", modtime, true, nil},
+		{"PUT", []string{"If-Match", `"v0", "v1"`}, `"v1"`, modtime, true, nil},
+		{"PUT", []string{"If-Match", `"v0"`}, "v1", modtime, true, ErrPreconditionFailed},
+		{"PUT", []string{"If-Match", `W/"v1"`}, "v1", modtime, true, ErrPreconditionFailed},
+		{"PUT", []string{"If-Match", `"v1"`}, "W/v1", modtime, true, ErrPreconditionFailed},
+		{"PUT", []string{"If-Match", `*`}, "v1", modtime, true, nil},
+		{"PUT", []string{"If-Match", `*`}, "", time.Time{}, true, ErrPreconditionFailed},
+		{"DELETE", []string{"If-Unmodified-Since", "Wed, 01 Jan 2020 12:00:00 GMT"}, "", modtime, true, nil},
+		{"DELETE", []string{"If-Unmodified-Since", "Wed, 01 Jan 2020 11:00:00 GMT"}, "", modtime, true, ErrPreconditionFailed},
+		{"DELETE", []string{"If-Unmodified-Since", "bad date"}, "", modtime, false, nil},
+		// If-Match takes precedence over If-Unmodified-Since
+		{"PATCH", []string{"If-Match", `"v1"`, "If-Unmodified-Since", "Wed, 01 Jan 2020 11:00:00 GMT"}, "v1", modtime, true, nil},
+		{"PUT", []string{"If-None-Match", "*"}, "", time.Time{}, true, nil},
+		{"PUT", []string{"If-None-Match", "*"}, "v1", modtime, true, ErrPreconditionFailed},
+		{"PUT", []string{"If-None-Match", `W/"v1"`}, "v1", modtime, true, ErrPreconditionFailed},
+		{"PUT", []string{"If-None-Match", `"v2"`}, "v1", modtime, true, nil},
+	} {
+		r := httptest.NewRequest(test.method, "/", nil)
+		for i := 0; i < len(test.headers); i += 2 {
+			r.Header.Set(test.headers[i], test.headers[i+1])
+		}
+		var c = newContext(httptest.NewRecorder(), r)
+		if err := c.CheckPreconditions(test.etag, test.modtime, test.required); err != test.err {
+			t.Errorf("%s %v %s: bad result: %v", test.method, test.headers, test.etag, err)
+		}
+	}
+
+	var mux = new(ServeMux)
+	mux.Handle("PUT", "/", func(c *Context) error {
+		return c.CheckPreconditions("v1", modtime, true)
+	})
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("PUT", "/", nil))
+	if w.Code != http.StatusPreconditionRequired {
+		t.Error("bad status:", w.Code)
+	}
+}
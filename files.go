@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// FileCompressor is implemented by Compressors whose precompressed static
+// files are stored next to the original ones with the file name extension
+// (for example, ".gz" for gzip).
+type FileCompressor interface {
+	Extension() string
+}
+
+// Extension returns the file name extension of gzip compressed files.
+func (gzipCompressor) Extension() string { return ".gz" }
+
+// fileCompressors returns the registered compressors supporting precompressed
+// files in order of server preference.
+func fileCompressors() []Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	var list = make([]Compressor, 0, len(compressors))
+	for _, c := range compressors {
+		if _, ok := c.(FileCompressor); ok {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// serveFile replies to the request with the contents of the named file from
+// the file system. Directories are listed only if enabled by DirListing.
+//
+// If the client accepts the content coding of the precompressed file stored
+// next to the requested one, this file is served with the Content-Type of the
+// original file. Range requests are supported only for the original file.
+func (c *Context) serveFile(files http.FileSystem, name string) error {
+	if name == "" || name[0] != '/' {
+		name = "/" + name
+	}
+	file, err := files.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		if c.dirListing() {
+			return c.serveDir(file, name)
+		}
+		return ErrNotFound
+	}
+	if encoded, encoding := c.openPrecompressed(files, name); encoded != nil {
+		defer encoded.Close()
+		var headers = c.Response.Header()
+		if headers.Get("Content-Type") == "" {
+			contentType, err := fileContentType(name, file)
+			if err != nil {
+				return err
+			}
+			headers.Set("Content-Type", contentType)
+		}
+		headers.Set("Content-Encoding", encoding)
+		headers.Add("Vary", "Accept-Encoding")
+		// ranges of the encoded data are not supported
+		c.Request.Header.Del("Range")
+		return c.ServeContent(name, fi.ModTime(), encoded)
+	}
+	return c.ServeContent(name, fi.ModTime(), file)
+}
+
+// openPrecompressed returns the precompressed file for the named file with the
+// content coding acceptable by client.
+func (c *Context) openPrecompressed(files http.FileSystem, name string) (http.File, string) {
+	var resp = c.Response.(*response)
+	if resp.noCompress || (resp.policy != nil && resp.policy.Disabled) {
+		return nil, ""
+	}
+	for _, compressor := range acceptableCompressors(
+		c.Header("Accept-Encoding"), fileCompressors()) {
+		file, err := files.Open(name +
+			compressor.(FileCompressor).Extension())
+		if err != nil {
+			continue
+		}
+		if fi, err := file.Stat(); err != nil || !fi.Mode().IsRegular() {
+			file.Close()
+			continue
+		}
+		return file, compressor.Encoding()
+	}
+	return nil, ""
+}
+
+// fileContentType returns the content type of the file by its name extension
+// or content.
+func fileContentType(name string, content io.ReadSeeker) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType, nil
+	}
+	var buf [512]byte
+	n, _ := io.ReadFull(content, buf[:])
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
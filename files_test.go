@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilesPrecompressed(t *testing.T) {
+	var dir = t.TempDir()
+	var css = strings.Repeat("body { color: red; }\n", 20)
+	os.WriteFile(filepath.Join(dir, "style.css"), []byte(css), 0644)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte(css), 0644)
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	gzw.Write([]byte(css))
+	gzw.Close()
+	os.WriteFile(filepath.Join(dir, "style.css.gz"), buf.Bytes(), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	var mux = new(ServeMux)
+	mux.Handle("GET", "/files/*name", Files(dir))
+	for _, test := range []struct {
+		path, acceptEncoding, rangeHeader string
+		code                              int
+		encoding, contentType, body       string
+	}{
+		{"/files/style.css", "gzip", "", 200, "gzip", "text/css; charset=utf-8", buf.String()},
+		{"/files/style.css", "gzip", "bytes=0-3", 200, "gzip", "text/css; charset=utf-8", buf.String()},
+		{"/files/style.css", "", "", 200, "", "text/css; charset=utf-8", css},
+		{"/files/style.css", "gzip;q=0, deflate", "", 200, "deflate", "text/css; charset=utf-8", ""},
+		{"/files/style.css", "", "bytes=0-3", 206, "", "text/css; charset=utf-8", "body"},
+		{"/files/app.js", "gzip", "bytes=0-3", 206, "", "", "body"},
+		{"/files/sub", "gzip", "", 404, "", "", ""},
+		{"/files/none.css", "gzip", "", 404, "", "", ""},
+	} {
+		r := httptest.NewRequest("GET", test.path, nil)
+		if test.acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		}
+		if test.rangeHeader != "" {
+			r.Header.Set("Range", test.rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		var info = test.path + " " + test.acceptEncoding + " " + test.rangeHeader
+		if w.Code != test.code {
+			t.Errorf("%s: bad status: %d", info, w.Code)
+		}
+		if test.code >= 400 {
+			continue
+		}
+		if encoding := w.Header().Get("Content-Encoding"); encoding != test.encoding {
+			t.Errorf("%s: bad encoding: %q", info, encoding)
+		}
+		if contentType := w.Header().Get("Content-Type"); test.contentType != "" &&
+			contentType != test.contentType {
+			t.Errorf("%s: bad content type: %q", info, contentType)
+		}
+		if test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: bad body: %q", info, w.Body.String())
+		}
+		if test.encoding == "gzip" && !strings.Contains(
+			strings.Join(w.Header()["Vary"], ","), "Accept-Encoding") {
+			t.Errorf("%s: vary header not set", info)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	HTTPFiles(http.Dir(dir), "style.css").ServeHTTP(w,
+		httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Error("bad status without params:", w.Code)
+	}
+}
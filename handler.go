@@ -2,7 +2,6 @@ package rest
 
 import (
 	"net/http"
-	"path/filepath"
 )
 
 // Handler describes an HTTP request handler.
@@ -11,7 +10,11 @@ type Handler func(*Context) error
 // ServeHTTP implements http.Handler interface.
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c := newContext(w, r)
-	if err := h(c); !c.IsWrote() {
+	err := h(c)
+	if err != nil {
+		c.ResetResponse() // the error replaces the buffered output
+	}
+	if !c.IsWrote() {
 		c.Write(err)
 	}
 	c.close()
@@ -64,14 +67,11 @@ func File(name string) Handler {
 // in the way as the last named parameter. Does not display the list of files
 // if the request is for a file directory in contrast to standard functions
 // http.FileServer.
+//
+// If the client accepts the compressed content, the precompressed file stored
+// next to the requested one (for example, with .gz extension) is served.
 func Files(dir string) Handler {
-	return func(c *Context) error {
-		if len(c.params) == 0 {
-			return ErrNotFound
-		}
-		filename := filepath.Join(dir, c.params[len(c.params)-1].Value)
-		return c.ServeFile(filename)
-	}
+	return HTTPFiles(http.Dir(dir), "")
 }
 
 // Data constantly gives specified in the settings data in response to the
@@ -104,7 +104,8 @@ func HTTPHandler(h http.Handler) Handler {
 }
 
 // HTTPFiles обеспечивает отдачу файлов с помощью http.Dir и аналогичных
-// вещей, которые поддерживают интерфейс http.FileSystem.
+// вещей, которые поддерживают интерфейс http.FileSystem. Как и Files,
+// поддерживает отдачу предварительно сжатых файлов.
 func HTTPFiles(files http.FileSystem, index string) Handler {
 	return func(c *Context) error {
 		if len(c.params) == 0 {
@@ -116,16 +117,6 @@ func HTTPFiles(files http.FileSystem, index string) Handler {
 		if name == "" {
 			name = index
 		}
-		// отдаем содержимое файла
-		file, err := files.Open(name)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		fi, err := file.Stat()
-		if err != nil {
-			return err
-		}
-		return c.ServeContent(name, fi.ModTime(), file)
+		return c.serveFile(files, name)
 	}
 }
@@ -0,0 +1,237 @@
+package rest
+
+import (
+	"html/template"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirEntry describes the directory listing item.
+type DirEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modtime"`
+	IsDir   bool      `json:"isDir"`
+}
+
+// dirListingKey is the context data key enabling directory listings.
+type dirListingKey struct{}
+
+// DirListing is the Handler enabling directory listings in Files and HTTPFiles.
+// It is used before them in the route handlers:
+//
+//	mux.Handle("GET", "/artifacts/*name", rest.DirListing, rest.Files(dir))
+//
+// The listing is rendered as HTML for browsers and as JSON array of DirEntry
+// for clients preferring application/json. It supports the query parameters:
+// "sort" (name, size or modtime), "order" (asc or desc), "page" (starting with
+// 1) and "limit" (items per page). Files beginning with a dot are not listed.
+func DirListing(c *Context) error {
+	c.SetData(dirListingKey{}, true)
+	return nil
+}
+
+// dirListing returns true if directory listings are enabled.
+func (c *Context) dirListing() bool {
+	enabled, _ := c.Data(dirListingKey{}).(bool)
+	return enabled
+}
+
+// serveDir replies to the request with the listing of the named directory.
+func (c *Context) serveDir(dir http.File, name string) error {
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		return err
+	}
+	var list = make([]DirEntry, 0, len(infos))
+	for _, fi := range infos {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		var entry = DirEntry{
+			Name:    fi.Name(),
+			ModTime: fi.ModTime().UTC(),
+			IsDir:   fi.IsDir(),
+		}
+		if !entry.IsDir {
+			entry.Size = fi.Size()
+		}
+		list = append(list, entry)
+	}
+	var (
+		sortBy = c.Query("sort")
+		desc   = c.Query("order") == "desc"
+	)
+	sort.SliceStable(list, func(i, j int) bool {
+		var a, b = list[i], list[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir // directories first
+		}
+		if desc {
+			a, b = b, a
+		}
+		switch sortBy {
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case "modtime":
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.Before(b.ModTime)
+			}
+		}
+		return a.Name < b.Name
+	})
+	// pagination
+	var total, page, limit = len(list), 1, 0
+	if n, err := strconv.Atoi(c.Query("limit")); err == nil && n > 0 {
+		limit = n
+		if n, err := strconv.Atoi(c.Query("page")); err == nil && n > 0 {
+			page = n
+		}
+		var from = len(list)
+		if page-1 <= len(list)/limit { // (page-1)*limit can overflow
+			from = (page - 1) * limit
+		}
+		list = list[from:]
+		if len(list) > limit {
+			list = list[:limit]
+		}
+	}
+	c.SetHeader("X-Total-Count", strconv.Itoa(total))
+	c.Response.Header().Add("Vary", "Accept") // JSON or HTML
+	if preferJSON(c.Header("Accept")) {
+		return c.Write(list)
+	}
+	c.SetContentType("text/html; charset=utf-8")
+	var base = c.Request.URL.Path
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	var pageURL = func(page int) string {
+		var query = url.Values{}
+		for _, key := range []string{"sort", "order", "limit"} {
+			if value := c.Query(key); value != "" {
+				query.Set(key, value)
+			}
+		}
+		query.Set("page", strconv.Itoa(page))
+		return "?" + query.Encode()
+	}
+	var data = struct {
+		Path      string
+		Base      string
+		Root      bool
+		Entries   []DirEntry
+		Prev      string
+		Next      string
+		SortOrder string
+	}{
+		Path:      path.Clean(name),
+		Base:      base,
+		Root:      path.Clean(name) == "/",
+		Entries:   list,
+		SortOrder: "desc",
+	}
+	if desc {
+		data.SortOrder = "asc"
+	}
+	if limit > 0 && page > 1 {
+		data.Prev = pageURL(page - 1)
+	}
+	if limit > 0 && page <= (total-1)/limit { // page*limit < total
+		data.Next = pageURL(page + 1)
+	}
+	return dirListingTemplate.Execute(c.Response, data)
+}
+
+// preferJSON returns true if the Accept header value prefers application/json
+// to text/html.
+func preferJSON(accept string) bool {
+	var jsonQ, htmlQ = -1.0, -1.0
+	for _, item := range strings.Split(accept, ",") {
+		mediatype, params, err := mime.ParseMediaType(item)
+		if err != nil {
+			continue
+		}
+		var q = 1.0
+		if value, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = value
+		}
+		var json, html float64 = -1, -1
+		switch mediatype {
+		case "application/json":
+			json = q + 0.002 // exact match is more specific
+		case "text/html":
+			html = q + 0.002
+		case "application/*":
+			json = q + 0.001
+		case "text/*":
+			html = q + 0.001
+		case "*/*":
+			json, html = q, q
+		}
+		if json > jsonQ {
+			jsonQ = json
+		}
+		if html > htmlQ {
+			htmlQ = html
+		}
+	}
+	return jsonQ > 0.002 && jsonQ > htmlQ
+}
+
+// dirListingTemplate is used to render the HTML directory listing.
+var dirListingTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"parent": func(base string) string {
+		var parent = path.Dir(strings.TrimSuffix(base, "/"))
+		if parent != "/" {
+			parent += "/"
+		}
+		return parent
+	},
+	"link": func(base string, entry DirEntry) string {
+		var link = base + url.PathEscape(entry.Name)
+		if entry.IsDir {
+			link += "/"
+		}
+		return link
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Index of {{.Path}}</title>
+</head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<thead><tr>
+<th><a href="?sort=name&amp;order={{.SortOrder}}">Name</a></th>
+<th><a href="?sort=size&amp;order={{.SortOrder}}">Size</a></th>
+<th><a href="?sort=modtime&amp;order={{.SortOrder}}">Modified</a></th>
+</tr></thead>
+<tbody>
+{{- if not .Root}}
+<tr><td><a href="{{parent .Base}}">../</a></td><td></td><td></td></tr>
+{{- end}}
+{{- range .Entries}}
+<tr><td><a href="{{link $.Base .}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td>{{if not .IsDir}}{{.Size}}{{end}}</td><td>{{.ModTime.Format "2006-01-02 15:04:05"}}</td></tr>
+{{- end}}
+</tbody>
+</table>
+{{- if .Prev}}
+<a href="{{.Prev}}">&larr; previous</a>
+{{- end}}
+{{- if .Next}}
+<a href="{{.Next}}">next &rarr;</a>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDirListing(t *testing.T) {
+	var dir = t.TempDir()
+	for i, name := range []string{"b.txt", "a.txt", "c.txt", ".hidden"} {
+		var filename = filepath.Join(dir, name)
+		os.WriteFile(filename, []byte(strings.Repeat("x", 10-i)), 0644)
+		var modtime = time.Date(2020, 1, i+1, 0, 0, 0, 0, time.UTC)
+		os.Chtimes(filename, modtime, modtime)
+	}
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	var mux = new(ServeMux)
+	mux.Handle("GET", "/files/*name", DirListing, Files(dir))
+	mux.Handle("GET", "/raw/*name", Files(dir))
+
+	var list = func(query string) []DirEntry {
+		r := httptest.NewRequest("GET", "/files/"+query, nil)
+		r.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		var entries []DirEntry
+		if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return entries
+	}
+	var names = func(entries []DirEntry) string {
+		var list = make([]string, len(entries))
+		for i, entry := range entries {
+			list[i] = entry.Name
+		}
+		return strings.Join(list, ",")
+	}
+	for query, result := range map[string]string{
+		"":                                  "sub,a.txt,b.txt,c.txt",
+		"?order=desc":                       "sub,c.txt,b.txt,a.txt",
+		"?sort=size":                        "sub,c.txt,a.txt,b.txt",
+		"?sort=modtime&order=desc":          "sub,c.txt,a.txt,b.txt",
+		"?limit=2":                          "sub,a.txt",
+		"?limit=2&page=2":                   "b.txt,c.txt",
+		"?limit=2&page=10":                  "",
+		"?limit=2&page=4611686018427387905": "",
+		"?limit=9223372036854775807&page=2": "",
+		"?sort=modtime&limit=3&page=1":      "sub,b.txt,a.txt",
+	} {
+		if names := names(list(query)); names != result {
+			t.Errorf("%q: bad listing: %s", query, names)
+		}
+	}
+	if entries := list(""); !entries[0].IsDir || entries[1].Size != 9 {
+		t.Errorf("bad entries: %+v", entries)
+	}
+
+	r := httptest.NewRequest("GET", "/files/?limit=1&page=2", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	var body = w.Body.String()
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") ||
+		!strings.Contains(body, `href="/files/a.txt"`) ||
+		!strings.Contains(body, `href="?limit=1&amp;page=1"`) ||
+		!strings.Contains(body, `href="?limit=1&amp;page=3"`) ||
+		strings.Contains(body, ".hidden") {
+		t.Error("bad html listing:", body)
+	}
+	if w.Header().Get("X-Total-Count") != "4" {
+		t.Error("bad total count:", w.Header().Get("X-Total-Count"))
+	}
+	if w.Header().Get("Vary") != "Accept" {
+		t.Error("bad vary:", w.Header().Get("Vary"))
+	}
+
+	// page*limit overflow
+	r = httptest.NewRequest("GET", "/files/?limit=2&page=4611686018427387905", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != 200 || strings.Contains(w.Body.String(), "page=4611686018427387906") {
+		t.Error("bad overflowed page:", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "/raw/", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != 404 {
+		t.Error("listing is not disabled by default:", w.Code)
+	}
+}
+
+func TestPreferJSON(t *testing.T) {
+	for accept, result := range map[string]bool{
+		"":                                 false,
+		"*/*":                              false,
+		"application/json":                 true,
+		"text/html,application/json;q=0.9": false,
+		"text/html;q=0.5,application/json": true,
+		"application/*, text/html;q=0.1":   true,
+		"text/*, application/json":         true,
+		"text/html,application/xhtml+xml,*/*;q=0.8": false,
+	} {
+		if preferJSON(accept) != result {
+			t.Errorf("bad negotiation for %q", accept)
+		}
+	}
+}
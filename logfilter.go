@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"math/rand"
+	"path"
+	"time"
+)
+
+// LogFilter selects the requests written to the access log of ServeMux. The
+// requests matching the skip rules are not logged. The successful requests
+// are sampled with SampleRate, but errors and slow requests are always logged.
+//
+// The requests executed longer than SlowRequest are logged as warnings with
+// the "slow" field, the route pattern and the timing breakdown: the "handler"
+// duration and the "write" duration of the response completion.
+type LogFilter struct {
+	SkipPaths   []string      // path patterns (path.Match) not logged
+	SkipRoutes  []string      // route patterns not logged
+	SkipStatus  []int         // status codes or classes (2 for 2xx) not logged
+	SampleRate  float64       // logged fraction of successful requests (0 - all)
+	SlowRequest time.Duration // threshold of the slow request (0 - disabled)
+}
+
+// slow returns true if the request duration exceeds the threshold.
+func (f *LogFilter) slow(duration time.Duration) bool {
+	return f != nil && f.SlowRequest > 0 && duration >= f.SlowRequest
+}
+
+// skip returns true if the request should not be logged.
+func (f *LogFilter) skip(c *Context, err error, slow bool) bool {
+	if f == nil {
+		return false
+	}
+	for _, pattern := range f.SkipPaths {
+		if matched, _ := path.Match(pattern, c.Request.URL.Path); matched {
+			return true
+		}
+	}
+	for _, route := range f.SkipRoutes {
+		if c.route != "" && route == c.route {
+			return true
+		}
+	}
+	var code = c.Status()
+	for _, status := range f.SkipStatus {
+		if status == code || status == code/100 {
+			return true
+		}
+	}
+	if err != nil || code >= 400 || slow {
+		return false // always logged
+	}
+	return f.SampleRate > 0 && f.SampleRate < 1 && rand.Float64() >= f.SampleRate
+}
@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFilter(t *testing.T) {
+	var buf bytes.Buffer
+	var mux = &ServeMux{
+		AccessLog: &AccessLog{Writer: &buf, Format: "{{.Path}} {{.Status}} {{.Fields.slow}}"},
+		LogFilter: &LogFilter{
+			SkipPaths:   []string{"/health", "/assets/*"},
+			SkipRoutes:  []string{"/skip/:id"},
+			SkipStatus:  []int{3, 404},
+			SampleRate:  1e-9,
+			SlowRequest: 10 * time.Millisecond,
+		},
+	}
+	mux.Handle("GET", "/health", Data("OK", "text/plain"))
+	mux.Handle("GET", "/assets/*file", Data("OK", "text/plain"))
+	mux.Handle("GET", "/skip/:id", Data("OK", "text/plain"))
+	mux.Handle("GET", "/ok", Data("OK", "text/plain"))
+	mux.Handle("GET", "/redirect", func(c *Context) error {
+		return c.Redirect(302, "/ok")
+	})
+	mux.Handle("GET", "/error", func(c *Context) error {
+		return ErrForbidden
+	})
+	mux.Handle("GET", "/slow", func(c *Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return c.Write("OK")
+	})
+
+	for _, path := range []string{"/health", "/assets/app.js", "/skip/1",
+		"/redirect", "/unknown", "/error", "/slow", "/ok", "/ok", "/ok"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if lines := buf.String(); lines != "/error 403 <no value>\n/slow 200 true\n" {
+		t.Errorf("bad log: %q", lines)
+	}
+}
+
+func TestLogFilter_Slow(t *testing.T) {
+	var buf bytes.Buffer
+	var mux = &ServeMux{
+		Logger:    slog.New(slog.NewTextHandler(&buf, nil)),
+		LogFilter: &LogFilter{SlowRequest: time.Nanosecond},
+	}
+	mux.Handle("GET", "/user/:id", Data("OK", "text/plain"))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/user/1", nil))
+	var line = buf.String()
+	for _, field := range []string{"level=WARN", "slow=true", "route=/user/:id", "handler=", "write="} {
+		if !strings.Contains(line, field) {
+			t.Errorf("no %s in log: %s", field, line)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"log/slog"
+
+	"github.com/mdigger/log"
+)
+
+// Logger describes the logger used by ServeMux for access and error logging.
+// The args are the pairs of the field names and values.
+//
+// The *slog.Logger from the standard library and the *log.Logger from
+// github.com/mdigger/log implement it directly:
+//
+//	mux.Logger = slog.Default()
+//	mux.Logger = log.New("http")
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+var (
+	_ Logger = (*slog.Logger)(nil)
+	_ Logger = (*log.Logger)(nil)
+)
+
+// Logger returns the logger of the request. It writes to the Logger of
+// ServeMux (or slog.Default if it is not set) with the request ID, method,
+// route pattern and the fields added by AddLogField, so the log lines emitted
+// during handling can be correlated with the access log entry.
+func (c *Context) Logger() Logger {
+	return requestLogger{c}
+}
+
+// requestLogger is the Logger adding the fields of the request.
+type requestLogger struct {
+	c *Context
+}
+
+func (l requestLogger) Info(msg string, args ...interface{}) {
+	l.logger().Info(msg, l.fields(args)...)
+}
+
+func (l requestLogger) Warn(msg string, args ...interface{}) {
+	l.logger().Warn(msg, l.fields(args)...)
+}
+
+func (l requestLogger) Error(msg string, args ...interface{}) {
+	l.logger().Error(msg, l.fields(args)...)
+}
+
+// logger returns the logger of ServeMux or the default one.
+func (l requestLogger) logger() Logger {
+	if l.c.logger != nil {
+		return l.c.logger
+	}
+	return slog.Default()
+}
+
+// fields returns the request fields followed by the args.
+func (l requestLogger) fields(args []interface{}) []interface{} {
+	var c = l.c
+	var fields = make([]interface{}, 0, 6+len(c.logFields)+len(args))
+	if id := c.RequestID(); id != "" {
+		fields = append(fields, "request_id", id)
+	}
+	fields = append(fields, "method", c.Request.Method)
+	if c.route != "" {
+		fields = append(fields, "route", c.route)
+	}
+	fields = append(fields, c.logFields...)
+	return append(fields, args...)
+}
@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogger_Slog(t *testing.T) {
+	var buf bytes.Buffer
+	var mux = &ServeMux{Logger: slog.New(slog.NewTextHandler(&buf, nil))}
+	mux.Handle("GET", "/", func(c *Context) error {
+		c.AddLogField("user", "test")
+		return c.Write("OK")
+	})
+	mux.Handle("GET", "/error", func(c *Context) error {
+		return errors.New("test error")
+	})
+	for _, test := range []struct {
+		path   string
+		fields []string
+	}{
+		{"/", []string{"level=INFO", `msg="GET /"`, "code=200", "user=test", "size=2"}},
+		{"/error", []string{"level=ERROR", "code=500", `error="test error"`}},
+		{"/unknown", []string{"level=ERROR", "code=404", `error="not found"`}},
+	} {
+		buf.Reset()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.path, nil))
+		var line = buf.String()
+		if strings.Contains(line, "BADKEY") {
+			t.Error("bad fields:", line)
+		}
+		for _, field := range test.fields {
+			if !strings.Contains(line, field) {
+				t.Errorf("%s: no %s in log: %s", test.path, field, line)
+			}
+		}
+	}
+}
+
+func TestContext_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	var mux = &ServeMux{
+		Logger:    slog.New(slog.NewTextHandler(&buf, nil)),
+		RequestID: new(RequestID),
+	}
+	mux.Handle("GET", "/user/:name", func(c *Context) error {
+		c.AddLogField("user", c.Param("name"))
+		c.Logger().Warn("handling", "step", 1)
+		return c.Write("OK")
+	})
+	var r = httptest.NewRequest("GET", "/user/test", nil)
+	r.Header.Set("X-Request-ID", "test-id")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	var lines = strings.Split(buf.String(), "\n")
+	for _, field := range []string{"level=WARN", "msg=handling", "request_id=test-id",
+		"method=GET", "route=/user/:name", "user=test", "step=1"} {
+		if !strings.Contains(lines[0], field) {
+			t.Errorf("no %s in log: %s", field, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], "user=test") {
+		t.Error("bad access log:", lines[1])
+	}
+}
@@ -0,0 +1,264 @@
+package rest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default buckets of the metrics histograms.
+var (
+	DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	DefaultSizeBuckets     = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+	DefaultRatioBuckets    = []float64{.1, .2, .3, .4, .5, .6, .7, .8, .9, 1}
+)
+
+// Metrics collects the metrics of ServeMux requests and serves them in the
+// Prometheus text exposition format:
+//
+//	metrics := new(rest.Metrics)
+//	mux := &rest.ServeMux{Metrics: metrics}
+//	mux.Handle("GET", "/metrics", metrics.Handle)
+//
+// The metrics are labeled by the method ("other" for unknown methods), the
+// route pattern ("unmatched" if the route is not found) and the status class
+// ("2xx"):
+//
+//	<namespace>_requests_total                   counter
+//	<namespace>_request_duration_seconds         histogram
+//	<namespace>_response_size_bytes              histogram (uncompressed)
+//	<namespace>_response_compression_ratio       histogram (compressed/original)
+//	<namespace>_requests_in_flight               gauge (without labels)
+type Metrics struct {
+	Namespace       string    // metric names prefix ("http" if empty)
+	DurationBuckets []float64 // duration buckets in seconds (default if nil)
+	SizeBuckets     []float64 // response size buckets (default if nil)
+	inFlight        int64
+	mu              sync.Mutex
+	series          map[metricLabels]*metricSeries
+}
+
+// metricLabels describes the labels of the metric.
+type metricLabels struct {
+	method, route, status string
+}
+
+// metricSeries contains the metrics with the same labels.
+type metricSeries struct {
+	count    uint64
+	duration *histogram
+	size     *histogram
+	ratio    *histogram
+}
+
+// histogram is the Prometheus histogram.
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // not cumulative
+	sum     float64
+	count   uint64
+}
+
+// newHistogram returns the new histogram with the buckets.
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// observe adds the value to the histogram.
+func (h *histogram) observe(value float64) {
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.sum += value
+	h.count++
+}
+
+// begin marks the start of the request.
+func (m *Metrics) begin() {
+	atomic.AddInt64(&m.inFlight, 1)
+}
+
+// end records the metrics of the completed request.
+func (m *Metrics) end(c *Context, duration time.Duration) {
+	atomic.AddInt64(&m.inFlight, -1)
+	var labels = metricLabels{
+		method: metricMethod(c),
+		route:  c.route,
+		status: strconv.Itoa(c.Status()/100) + "xx",
+	}
+	if labels.route == "" {
+		labels.route = "unmatched"
+	}
+	var rw = c.Response.(*response)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.series == nil {
+		m.series = make(map[metricLabels]*metricSeries)
+	}
+	var series = m.series[labels]
+	if series == nil {
+		series = &metricSeries{
+			duration: newHistogram(m.buckets(m.DurationBuckets, DefaultDurationBuckets)),
+			size:     newHistogram(m.buckets(m.SizeBuckets, DefaultSizeBuckets)),
+			ratio:    newHistogram(DefaultRatioBuckets),
+		}
+		m.series[labels] = series
+	}
+	series.count++
+	series.duration.observe(duration.Seconds())
+	series.size.observe(float64(rw.written))
+	if rw.compressed && rw.written > 0 && rw.encoded > 0 {
+		series.ratio.observe(float64(rw.encoded) / float64(rw.written))
+	}
+}
+
+// standardMethods contains the HTTP methods of RFC 9110 and RFC 5789.
+var standardMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true,
+	"DELETE": true, "CONNECT": true, "OPTIONS": true, "TRACE": true,
+}
+
+// metricMethod returns the method label of the request. The methods other
+// than standard and not matched by the route are labeled as "other", so the
+// clients cannot create the unbounded number of series.
+func metricMethod(c *Context) string {
+	if method := c.Request.Method; standardMethods[method] || c.route != "" {
+		return method
+	}
+	return "other"
+}
+
+// buckets returns the sorted buckets or the default ones.
+func (m *Metrics) buckets(buckets, defaults []float64) []float64 {
+	if len(buckets) == 0 {
+		return defaults
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return buckets
+}
+
+// Handle serves the metrics in the Prometheus text exposition format.
+func (m *Metrics) Handle(c *Context) error {
+	c.SetContentType("text/plain; version=0.0.4; charset=utf-8")
+	c.SetHeader("Cache-Control", "no-store")
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return err
+	}
+	return c.Write(buf.Bytes())
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var namespace = m.Namespace
+	if namespace == "" {
+		namespace = "http"
+	}
+	m.mu.Lock()
+	var labels = make([]metricLabels, 0, len(m.series))
+	for l := range m.series {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		var a, b = labels[i], labels[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	var mw = &metricsWriter{w: w}
+	mw.header(namespace+"_requests_total", "counter",
+		"Total number of HTTP requests.")
+	for _, l := range labels {
+		mw.sample(namespace+"_requests_total", l.String(), "",
+			float64(m.series[l].count))
+	}
+	for _, metric := range []struct {
+		name, help string
+		get        func(*metricSeries) *histogram
+	}{
+		{"_request_duration_seconds", "HTTP request duration in seconds.",
+			func(s *metricSeries) *histogram { return s.duration }},
+		{"_response_size_bytes", "HTTP response uncompressed size in bytes.",
+			func(s *metricSeries) *histogram { return s.size }},
+		{"_response_compression_ratio", "HTTP response compressed to uncompressed size ratio.",
+			func(s *metricSeries) *histogram { return s.ratio }},
+	} {
+		var name = namespace + metric.name
+		mw.header(name, "histogram", metric.help)
+		for _, l := range labels {
+			mw.histogram(name, l.String(), metric.get(m.series[l]))
+		}
+	}
+	m.mu.Unlock()
+	mw.header(namespace+"_requests_in_flight", "gauge",
+		"Number of HTTP requests being served.")
+	mw.sample(namespace+"_requests_in_flight", "", "",
+		float64(atomic.LoadInt64(&m.inFlight)))
+	return mw.n, mw.err
+}
+
+// String returns the labels in the exposition format without braces.
+func (l metricLabels) String() string {
+	return `method="` + escapeLabel(l.method) + `",route="` +
+		escapeLabel(l.route) + `",status="` + l.status + `"`
+}
+
+// escapeLabel escapes the label value.
+var escapeLabel = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+
+// metricsWriter writes the text exposition format.
+type metricsWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// printf writes the formatted line.
+func (mw *metricsWriter) printf(format string, args ...interface{}) {
+	if mw.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(mw.w, format, args...)
+	mw.n += int64(n)
+	mw.err = err
+}
+
+// header writes the HELP and TYPE lines of the metric.
+func (mw *metricsWriter) header(name, typ, help string) {
+	mw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes the metric sample with the labels and additional label.
+func (mw *metricsWriter) sample(name, labels, extra string, value float64) {
+	switch {
+	case labels != "" && extra != "":
+		labels = "{" + labels + "," + extra + "}"
+	case labels != "" || extra != "":
+		labels = "{" + labels + extra + "}"
+	}
+	mw.printf("%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// histogram writes the histogram samples.
+func (mw *metricsWriter) histogram(name, labels string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		mw.sample(name+"_bucket", labels,
+			`le="`+strconv.FormatFloat(bound, 'g', -1, 64)+`"`, float64(cumulative))
+	}
+	mw.sample(name+"_bucket", labels, `le="+Inf"`, float64(h.count))
+	mw.sample(name+"_sum", labels, "", h.sum)
+	mw.sample(name+"_count", labels, "", float64(h.count))
+}
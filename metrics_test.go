@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	var metrics = &Metrics{Namespace: "test", DurationBuckets: []float64{10, 0.5}}
+	var mux = &ServeMux{Metrics: metrics, Timeout: time.Second}
+	mux.Handle("GET", "/metrics", metrics.Handle)
+	mux.Handle("GET", "/user/:name", func(c *Context) error {
+		return c.Write(strings.Repeat("text ", 1000))
+	})
+	mux.Handle("POST", "/user/:name", func(c *Context) error {
+		return ErrForbidden
+	})
+	mux.Handle("PURGE", "/user/:name", func(c *Context) error {
+		return nil
+	})
+	mux.Handle("GET", "/abort", func(c *Context) error {
+		panic(http.ErrAbortHandler)
+	})
+
+	for _, request := range []struct {
+		method, path string
+	}{
+		{"GET", "/user/a"}, {"GET", "/user/b"}, {"POST", "/user/a"}, {"GET", "/unknown"},
+		{"PURGE", "/user/a"}, {"FOO", "/user/a"}, {"BAR", "/unknown"},
+	} {
+		r := httptest.NewRequest(request.method, request.path, nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		mux.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Error("bad abort panic:", p)
+			}
+		}()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	}()
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	var body = w.Body.String()
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Error("bad content type:", w.Header().Get("Content-Type"))
+	}
+	for _, line := range []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{method="GET",route="/user/:name",status="2xx"} 2`,
+		`test_requests_total{method="POST",route="/user/:name",status="4xx"} 1`,
+		`test_requests_total{method="GET",route="unmatched",status="4xx"} 1`,
+		`test_requests_total{method="PURGE",route="/user/:name",status="2xx"} 1`,
+		`test_requests_total{method="other",route="unmatched",status="4xx"} 2`,
+		"# TYPE test_request_duration_seconds histogram",
+		`test_request_duration_seconds_bucket{method="GET",route="/user/:name",status="2xx",le="0.5"} 2`,
+		`test_request_duration_seconds_bucket{method="GET",route="/user/:name",status="2xx",le="10"} 2`,
+		`test_request_duration_seconds_bucket{method="GET",route="/user/:name",status="2xx",le="+Inf"} 2`,
+		`test_request_duration_seconds_count{method="GET",route="/user/:name",status="2xx"} 2`,
+		`test_response_size_bytes_bucket{method="GET",route="/user/:name",status="2xx",le="1000"} 0`,
+		`test_response_size_bytes_bucket{method="GET",route="/user/:name",status="2xx",le="10000"} 2`,
+		`test_response_size_bytes_sum{method="GET",route="/user/:name",status="2xx"} 10000`,
+		`test_response_compression_ratio_bucket{method="GET",route="/user/:name",status="2xx",le="0.1"} 2`,
+		`test_response_compression_ratio_count{method="GET",route="/user/:name",status="2xx"} 2`,
+		"# TYPE test_requests_in_flight gauge",
+		"test_requests_in_flight 1",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("no %s", line)
+		}
+	}
+	if t.Failed() {
+		t.Log(body)
+	}
+}
@@ -3,10 +3,10 @@ package rest
 import (
 	"fmt"
 	"net/http"
+	"runtime/debug"
 	"strings"
 	"time"
 
-	"github.com/mdigger/log"
 	"github.com/mdigger/router"
 )
 
@@ -18,18 +18,74 @@ import (
 // 	/user/:name
 // 	/user/:name/files
 // 	/user/:name/files/*filename
+//
+// The panic in the handler is recovered and replied with
+// ErrInternalServerError. The panic value and the stack trace are always
+// written to the Logger (or slog.Default if it is not set) and passed to
+// PanicHandler, if it is set. The panic value is also added to the access log.
+//
+// Compressed request bodies are transparently decompressed according to the
+// Content-Encoding header with the size limit MaxDecompressedSize
+// (DefaultMaxDecompressedSize if zero). The negative limit disables the
+// decompression.
 type ServeMux struct {
-	Headers map[string]string // additional http.Headers
-	Encoder Encoder           // data Encoder (used default if nil)
-	Logger  *log.Logger       // access logger (if not nil)
-	routers map[string]*router.Paths
+	Headers             map[string]string  // additional http.Headers
+	Encoder             Encoder            // data Encoder (used default if nil)
+	Logger              Logger             // access logger (if not nil)
+	AccessLog           *AccessLog         // formatted access log (if not nil)
+	LogFilter           *LogFilter         // access log filter (all if nil)
+	RequestID           *RequestID         // request ID assignment (if not nil)
+	Tracer              *Tracer            // request tracing (if not nil)
+	Metrics             *Metrics           // request metrics (if not nil)
+	Compression         *CompressionPolicy // response compression (default if nil)
+	MaxDecompressedSize int64              // request body decompression limit
+	AutoETag            bool               // generate ETag for responses
+	CacheControl        *CacheControl      // default Cache-Control of routes
+	BufferSize          int                // buffered mode limit (0 to disable)
+	Timeout             time.Duration      // handlers execution timeout (see HandleTimeout)
+	PanicHandler        PanicHandler       // panic hook (error tracker)
+	CheckOrigin         OriginChecker      // WebSocket origin check (same if nil)
+	routers             map[string]*router.Paths
+}
+
+// route is the registered handler with its path pattern.
+type route struct {
+	pattern    string
+	handler    Handler
+	timeout    time.Duration // execution timeout of the route
+	ownTimeout bool          // the timeout overrides ServeMux.Timeout
 }
 
+// PanicHandler is called with the recovered panic value and the stack trace.
+type PanicHandler func(c *Context, value interface{}, stack []byte)
+
 // Handle registers the handler for the given method and pattern. If you specify
 // multiple handlers, they will be run sequentially until one of them does not
 // return a non-zero response status code or error. When you specify the path
 // pattern, you can use named parameters.
 func (mux *ServeMux) Handle(method, pattern string, handlers ...Handler) {
+	mux.handle(method, &route{pattern: pattern, handler: Handlers(handlers...)})
+}
+
+// HandleTimeout registers the handlers like Handle with the execution timeout
+// overriding ServeMux.Timeout for this route. Zero timeout disables it, which
+// is required for the streaming handlers, like Broker.Subscribe, and
+// WebSocket connections:
+//
+//	mux := &rest.ServeMux{Timeout: 5 * time.Second}
+//	mux.HandleTimeout(time.Minute, "POST", "/reports", report)
+//	mux.HandleTimeout(0, "GET", "/events", broker.Subscribe())
+func (mux *ServeMux) HandleTimeout(timeout time.Duration, method, pattern string, handlers ...Handler) {
+	mux.handle(method, &route{
+		pattern:    pattern,
+		handler:    Handlers(handlers...),
+		timeout:    timeout,
+		ownTimeout: true,
+	})
+}
+
+// handle registers the route for the method.
+func (mux *ServeMux) handle(method string, route *route) {
 	if method == "" {
 		method = "GET"
 	}
@@ -43,7 +99,7 @@ func (mux *ServeMux) Handle(method, pattern string, handlers ...Handler) {
 		r = new(router.Paths)
 		mux.routers[method] = r
 	}
-	if err := r.Add(pattern, Handlers(handlers...)); err != nil {
+	if err := r.Add(route.pattern, route); err != nil {
 		panic(err) // the handler does not suit us for some reason
 	}
 }
@@ -55,7 +111,16 @@ func (mux *ServeMux) Handle(method, pattern string, handlers ...Handler) {
 // handlers for other methods, it returns the ErrMethodNotAllowed and the header
 // is passed the list of methods that can be applied to the given path.
 // Otherwise, returns the ErrNotFound.
-func (mux *ServeMux) Handler(c *Context) (err error) {
+func (mux *ServeMux) Handler(c *Context) error {
+	h, _ := mux.lookup(c)
+	return h(c)
+}
+
+// lookup adds the HTTP headers and returns the handler of the route for the
+// request and its execution timeout, setting the route pattern and its named
+// parameters. If the route is not found, the returned handler replies with the
+// redirect or the error.
+func (mux *ServeMux) lookup(c *Context) (Handler, time.Duration) {
 	// add HTTP headers
 	if len(mux.Headers) > 0 {
 		for key, value := range mux.Headers {
@@ -69,8 +134,13 @@ func (mux *ServeMux) Handler(c *Context) (err error) {
 	)
 	if routers := mux.routers[method]; routers != nil {
 		if handler, params := routers.Lookup(urlPath); handler != nil {
+			var route = handler.(*route)
 			c.params = append(c.params, params...)
-			return handler.(Handler)(c) // execute the request handler
+			c.route = route.pattern
+			if route.ownTimeout {
+				return route.handler, route.timeout
+			}
+			return route.handler, mux.Timeout
 		}
 
 		// try add/remove slash at the end
@@ -84,7 +154,9 @@ func (mux *ServeMux) Handler(c *Context) (err error) {
 			if method != "GET" && method != "HEAD" {
 				code = http.StatusPermanentRedirect
 			}
-			return c.Redirect(code, urlPath)
+			return func(c *Context) error {
+				return c.Redirect(code, urlPath)
+			}, 0
 		}
 	}
 	// handler for request method not found
@@ -97,9 +169,9 @@ func (mux *ServeMux) Handler(c *Context) (err error) {
 	if len(methods) > 0 {
 		// allowed other methods
 		c.SetHeader("Allow", strings.Join(methods, ", "))
-		return ErrMethodNotAllowed
+		return func(*Context) error { return ErrMethodNotAllowed }, 0
 	}
-	return ErrNotFound
+	return func(*Context) error { return ErrNotFound }, 0
 }
 
 // ServeHTTP implements http.Handler interface.
@@ -107,35 +179,148 @@ func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var started = time.Now()
 	var context = newContext(w, r)
 	context.Encoder = mux.Encoder
-	err := mux.Handler(context)
+	context.logger = mux.Logger
+	context.checkOrigin = mux.CheckOrigin
+	context.Response.(*response).policy = mux.Compression
+	context.Response.(*response).autoETag = mux.AutoETag
+	context.Response.(*response).bufferLimit = mux.BufferSize
+	if mux.CacheControl != nil {
+		context.Response.(*response).cacheControl = mux.CacheControl.String()
+	}
+	if mux.RequestID != nil {
+		mux.RequestID.Handle(context)
+	}
+	var (
+		span    *Span
+		err     error
+		handled time.Time
+	)
+	if mux.Tracer != nil {
+		span = mux.Tracer.start(context)
+	}
+	if mux.Metrics != nil {
+		mux.Metrics.begin()
+	}
+	// the request is completed even if the response is aborted with panic
+	defer func() {
+		if p := recover(); p != nil {
+			defer panic(p)
+			err = fmt.Errorf("panic: %v", p)
+		}
+		if handled.IsZero() {
+			handled = time.Now()
+		}
+		if span != nil {
+			mux.Tracer.finish(context, span, err)
+		}
+		if mux.Metrics != nil {
+			mux.Metrics.end(context, time.Since(started))
+		}
+		mux.log(context, started, handled, err)
+	}()
+	err = mux.decompress(context)
+	if err == nil {
+		err = mux.serve(context)
+	}
+	if err != nil {
+		context.ResetResponse() // the error replaces the buffered output
+	}
 	if !context.IsWrote() {
 		context.Write(err)
 	}
+	handled = time.Now()
 	context.close()
-	// output information to the log
+}
+
+// log outputs information about the request to the log.
+func (mux *ServeMux) log(c *Context, started, handled time.Time, err error) {
+	if mux.Logger == nil && mux.AccessLog == nil {
+		return
+	}
+	var slow = mux.LogFilter.slow(time.Since(started))
+	if slow {
+		c.AddLogField("slow", true)
+		c.AddLogField("route", c.route)
+		c.AddLogField("handler", handled.Sub(started))
+		c.AddLogField("write", time.Since(handled))
+	}
+	if mux.LogFilter.skip(c, err, slow) {
+		return
+	}
 	if mux.Logger != nil {
-		code := context.Status()
-		msg := fmt.Sprintf("%s %s",
-			context.Request.Method, context.Request.RequestURI)
-		log := mux.Logger.With(
-			"code", code,
-			context.logFields,
-			"size", context.ContentLength(),
+		code := c.Status()
+		msg := fmt.Sprintf("%s %s", c.Request.Method, c.Request.RequestURI)
+		fields := append([]interface{}{"code", code}, c.logFields...)
+		fields = append(fields,
+			"size", c.ContentLength(),
 			"duration", time.Since(started),
-			"gzip", context.Compressed(),
-			// "ip", context.RealIP(),
+			"gzip", c.Compressed(),
+			// "ip", c.RealIP(),
 		)
 		switch {
 		case err != nil:
-			log.Error(msg, err)
-		case code < 400:
-			log.Info(msg)
+			mux.Logger.Error(msg, append(fields, "error", err)...)
+		case code < 400 && !slow:
+			mux.Logger.Info(msg, fields...)
 		case code < 500:
-			log.Warn(msg)
+			mux.Logger.Warn(msg, fields...)
 		default:
-			log.Error(msg)
+			mux.Logger.Error(msg, fields...)
 		}
 	}
+	if mux.AccessLog != nil {
+		mux.AccessLog.Log(newAccessLogEntry(c, started, err))
+	}
+}
+
+// serve executes the handler with the timeout, recovering from its panic.
+func (mux *ServeMux) serve(c *Context) (err error) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		var stack []byte
+		if gp, ok := p.(*goroutinePanic); ok {
+			p, stack = gp.value, gp.stack
+		}
+		if p == http.ErrAbortHandler {
+			panic(p) // abort the response
+		}
+		if stack == nil {
+			stack = debug.Stack()
+		}
+		// reported regardless of the access log and its filter
+		c.Logger().Error("panic recovered", "panic", fmt.Sprint(p),
+			"stack", string(stack))
+		c.AddLogField("panic", fmt.Sprint(p))
+		if mux.PanicHandler != nil {
+			mux.PanicHandler(c, p, stack)
+		}
+		err = ErrInternalServerError
+	}()
+	// the route is known even if the handler times out
+	h, timeout := mux.lookup(c)
+	if timeout > 0 {
+		return Timeout(timeout, h)(c)
+	}
+	return h(c)
+}
+
+// decompress replaces the request body with the decompressed one.
+func (mux *ServeMux) decompress(c *Context) error {
+	var limit = mux.MaxDecompressedSize
+	switch {
+	case limit < 0:
+		return nil
+	case limit == 0:
+		limit = DefaultMaxDecompressedSize
+	}
+	err := decompressBody(c.Request, limit)
+	if err == ErrUnsupportedMediaType {
+		c.SetHeader("Accept-Encoding", strings.Join(decompressors(), ", "))
+	}
+	return err
 }
 
 type (
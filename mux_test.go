@@ -1,9 +1,13 @@
 package rest
 
 import (
+	"bytes"
+	"log/slog"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mdigger/log"
 )
@@ -59,3 +63,77 @@ func TestServeMux(t *testing.T) {
 	w = httptest.NewRecorder()
 	mux.ServeHTTP(w, r)
 }
+
+func TestServeMux_Panic(t *testing.T) {
+	var (
+		value interface{}
+		stack []byte
+	)
+	mux := &ServeMux{
+		Logger: log.New("http"),
+		PanicHandler: func(c *Context, v interface{}, s []byte) {
+			value, stack = v, s
+		},
+	}
+	mux.Handle("GET", "/", func(c *Context) error {
+		panic("test panic")
+	})
+	mux.Handle("GET", "/timeout", Timeout(time.Second, func(c *Context) error {
+		panic("timeout panic")
+	}))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "internal server error") {
+		t.Error("bad panic response:", w.Code, w.Body.String())
+	}
+	if value != "test panic" || !strings.Contains(string(stack), "mux_test.go") {
+		t.Error("bad panic handler arguments:", value)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/timeout", nil))
+	if w.Code != 500 || value != "timeout panic" ||
+		!strings.Contains(string(stack), "mux_test.go") {
+		t.Error("bad timeout panic:", w.Code, value)
+	}
+
+	// the abort panic of the handler with timeout aborts the response
+	value = nil
+	mux.Handle("GET", "/abort", Timeout(time.Second, func(c *Context) error {
+		panic(http.ErrAbortHandler)
+	}))
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Error("bad abort panic:", p)
+			}
+		}()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	}()
+	if value != nil {
+		t.Error("abort panic is passed to panic handler:", value)
+	}
+
+	// the panic is logged even if the request is skipped by the filter
+	var buf bytes.Buffer
+	mux.Logger = slog.New(slog.NewTextHandler(&buf, nil))
+	mux.LogFilter = &LogFilter{SkipRoutes: []string{"/"}}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(buf.String(), `panic="test panic"`) ||
+		!strings.Contains(buf.String(), "mux_test.go") {
+		t.Error("panic is not logged:", buf.String())
+	}
+
+	// the default logger is used without Logger and AccessLog
+	var logger = slog.Default()
+	defer slog.SetDefault(logger)
+	buf.Reset()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	mux = new(ServeMux)
+	mux.Handle("GET", "/", func(c *Context) error {
+		panic("test panic")
+	})
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(buf.String(), `panic="test panic"`) {
+		t.Error("panic is not logged by default logger:", buf.String())
+	}
+}
@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// recordedResponse contains the response captured from the handler.
+type recordedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// recorder implements http.ResponseWriter collecting the response.
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header            { return r.header }
+func (r *recorder) Write(data []byte) (int, error) { return r.body.Write(data) }
+func (r *recorder) WriteHeader(code int)           { r.code = code }
+
+// record executes the handler with the copy of the request context and
+// returns the captured uncompressed response. If the handler returns an error
+// without writing the response, the error is written as usual. The copy of
+// the context is returned for inspection of the data set by the handler.
+func (c *Context) record(h Handler) (*recordedResponse, *Context, error) {
+	sub, rec := c.recording()
+	err := h(sub)
+	c.logFields, c.route = sub.logFields, sub.route
+	return rec.result(sub, err), sub, err
+}
+
+// recording returns the copy of the request context writing the response to
+// the recorder.
+func (c *Context) recording() (*Context, *recorder) {
+	var rec = &recorder{header: make(http.Header), code: http.StatusOK}
+	var sub = *c
+	sub.Response = &response{
+		ResponseWriter: rec,
+		code:           http.StatusOK,
+		writer:         &rec.body,
+		request:        c.Request,
+		noCompress:     true,
+		cacheControl:   c.Response.(*response).cacheControl,
+		bufferLimit:    c.Response.(*response).bufferLimit,
+	}
+	sub.query = nil
+	sub.logFields = c.logFields[:len(c.logFields):len(c.logFields)]
+	return &sub, rec
+}
+
+// result completes the response of the context returned by recording with
+// the handler error and returns it.
+func (r *recorder) result(c *Context, err error) *recordedResponse {
+	if err != nil {
+		c.ResetResponse() // the error replaces the buffered output
+	}
+	if !c.IsWrote() {
+		c.Write(err)
+	}
+	c.close()
+	return &recordedResponse{
+		Status: r.code,
+		Header: r.header,
+		Body:   r.body.Bytes(),
+	}
+}
+
+// writeTo replies to the request with the recorded response.
+func (r *recordedResponse) writeTo(c *Context) error {
+	var header = c.Response.Header()
+	for key, values := range r.Header {
+		header[key] = append([]string(nil), values...)
+	}
+	c.SetStatus(r.Status)
+	_, err := c.Response.Write(r.Body)
+	return err
+}
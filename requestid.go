@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// DefaultRequestIDHeader is the default header of the request ID.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// RequestID assigns the ID to the request for correlation. It is set for all
+// routes with ServeMux.RequestID or used as the route Handler with the Handle
+// method.
+//
+// The ID is taken from the request header, if it is valid (by default up to
+// 128 letters, digits and "-_.:+/="), or generated. It is
+// available with Context.RequestID, echoed in the response header and added to
+// the access log, the request logger and the error responses. The request
+// context carries the ID for the outgoing calls (see RequestIDFromContext).
+type RequestID struct {
+	Header   string               // header name (DefaultRequestIDHeader if empty)
+	Validate func(id string) bool // validate the incoming ID (default if nil)
+	Generate func() string        // generate the new ID (random 128 bits if nil)
+}
+
+// requestIDKey is the request context key of the request ID.
+type requestIDKey struct{}
+
+// Handle assigns the ID to the request.
+func (rid *RequestID) Handle(c *Context) error {
+	var header = rid.Header
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	var validate = rid.Validate
+	if validate == nil {
+		validate = validRequestID
+	}
+	var id = c.Header(header)
+	if id == "" || !validate(id) {
+		if rid.Generate != nil {
+			id = rid.Generate()
+		} else {
+			id = newRequestID()
+		}
+	}
+	c.reqID = id
+	c.SetHeader(header, id)
+	c.WithValue(requestIDKey{}, id)
+	return nil
+}
+
+// RequestID returns the ID of the request assigned by RequestID.
+func (c *Context) RequestID() string {
+	return c.reqID
+}
+
+// RequestIDFromContext returns the request ID from the context. It allows to
+// pass the ID to outgoing HTTP calls made with the request context:
+//
+//	r, _ := http.NewRequestWithContext(c, "GET", url, nil)
+//	r.Header.Set("X-Request-ID", rest.RequestIDFromContext(c))
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID returns true if the ID has 1-128 characters of letters,
+// digits and "-_.:+/=".
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns the new random request ID.
+func newRequestID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
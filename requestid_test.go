@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		id  string
+		mux = &ServeMux{
+			RequestID: new(RequestID),
+			AccessLog: &AccessLog{Writer: &buf, Format: "{{.RequestID}}"},
+		}
+	)
+	mux.Handle("GET", "/", func(c *Context) error {
+		id = c.RequestID()
+		if RequestIDFromContext(c.Request.Context()) != id {
+			t.Error("no request id in request context")
+		}
+		return ErrForbidden
+	})
+	var request = func(header string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("X-Request-ID", header)
+		}
+		w := httptest.NewRecorder()
+		buf.Reset()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("incoming-id.1")
+	if id != "incoming-id.1" || w.Header().Get("X-Request-ID") != id ||
+		buf.String() != id+"\n" ||
+		!strings.Contains(w.Body.String(), `"request_id": "incoming-id.1"`) {
+		t.Error("bad incoming id:", id, w.Header(), buf.String(), w.Body.String())
+	}
+	for _, header := range []string{"", "bad id", strings.Repeat("a", 129)} {
+		w = request(header)
+		if len(id) != 32 || id == header || w.Header().Get("X-Request-ID") == "" {
+			t.Errorf("bad generated id for %q: %q", header, id)
+		}
+	}
+
+	// per route with custom header and generator
+	var rid = &RequestID{
+		Header:   "X-Correlation-ID",
+		Generate: func() string { return "generated" },
+	}
+	mux = new(ServeMux)
+	mux.Handle("GET", "/", rid.Handle, func(c *Context) error {
+		return c.Write(c.RequestID())
+	})
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Body.String() != "generated" || w.Header().Get("X-Correlation-ID") != "generated" {
+		t.Error("bad route id:", w.Body.String(), w.Header())
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/unknown", nil))
+	if strings.Contains(w.Body.String(), "request_id") {
+		t.Error("request id without assignment:", w.Body.String())
+	}
+}
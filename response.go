@@ -1,23 +1,50 @@
 package rest
 
 import (
-	"compress/gzip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"io"
+	"math"
+	"net"
 	"net/http"
-	"strings"
-	"sync"
+	"strconv"
 )
 
 // response implements http.ResponseWriter interface, adding support for some
 // methods.
 type response struct {
 	http.ResponseWriter
-	code        int
-	request     *http.Request
-	writer      io.Writer
-	wroteHeader bool
-	compressed  bool
-	written     int64
+	code         int
+	request      *http.Request
+	writer       io.Writer
+	wroteHeader  bool
+	compressed   bool
+	compressor   Compressor         // used compressor
+	level        int                // used compression level
+	policy       *CompressionPolicy // compression policy
+	noCompress   bool               // compression disabled
+	buffered     bool               // the data is buffered before decision
+	autoETag     bool               // generate ETag for buffered response
+	buffer       []byte             // buffered data
+	cacheControl string             // default Cache-Control
+	bufferLimit  int                // buffered mode size limit
+	hijacked     bool
+	written      int64 // uncompressed size
+	encoded      int64 // compressed size
+}
+
+// countWriter counts the bytes written to the Writer.
+type countWriter struct {
+	io.Writer
+	count *int64
+}
+
+func (w *countWriter) Write(data []byte) (int, error) {
+	n, err := w.Writer.Write(data)
+	*w.count += int64(n)
+	return n, err
 }
 
 // WriteHeader sets the response status code.
@@ -29,6 +56,7 @@ func (rw *response) WriteHeader(code int) {
 
 // writeHeader really writes the response status code in the response.
 func (rw *response) writeHeader() {
+	rw.setDefaultCacheControl()
 	rw.ResponseWriter.WriteHeader(rw.code) // real writing header status
 	rw.wroteHeader = true                  // block rewriting headers
 }
@@ -36,8 +64,16 @@ func (rw *response) writeHeader() {
 // setDataHeaders sets the Content-Type header and configures compression
 // support response.
 func (rw *response) setDataHeaders(data []byte) {
+	if compressor := rw.selectCompressor(data); compressor != nil {
+		rw.compress(compressor)
+	}
+}
+
+// selectCompressor sets the Content-Type header and returns the compressor
+// for the response or nil if the data should not be compressed.
+func (rw *response) selectCompressor(data []byte) Compressor {
 	if len(data) == 0 {
-		return
+		return nil
 	}
 	var headers = rw.Header() // response headers
 	// writing Content-Type
@@ -46,31 +82,79 @@ func (rw *response) setDataHeaders(data []byte) {
 		contentType = http.DetectContentType(data)
 		headers.Set("Content-Type", contentType)
 	}
-	// check compression support in request header
-	if !strings.Contains(rw.request.Header.Get("Accept-Encoding"), "gzip") ||
-		!isCompress(contentType) {
-		return
+	// the content is already encoded or should not be compressed
+	var policy = rw.policy
+	if rw.noCompress || (policy != nil && policy.Disabled) ||
+		rw.code == http.StatusPartialContent ||
+		headers.Get("Content-Encoding") != "" ||
+		!policy.compressible(contentType) {
+		return nil
+	}
+	// small data is not compressed
+	if policy != nil && policy.MinSize > 0 {
+		var size = int64(len(data))
+		if length, err := strconv.ParseInt(
+			headers.Get("Content-Length"), 10, 64); err == nil {
+			size = length
+		}
+		if size < int64(policy.MinSize) {
+			return nil
+		}
 	}
+	headers.Add("Vary", "Accept-Encoding")
+	// select compression supported in request header
+	return policy.negotiate(rw.request.Header.Get("Accept-Encoding"))
+}
+
+// compress configures the response compression.
+func (rw *response) compress(compressor Compressor) {
+	var headers = rw.Header()
 	// remove the header compression support, not to install it again
 	rw.request.Header.Del("Accept-Encoding")
-	headers.Add("Vary", "Accept-Encoding")
-	headers.Set("Content-Encoding", "gzip")
+	headers.Set("Content-Encoding", compressor.Encoding())
 	headers.Del("Content-Length")
 	if rw.request.Method == "HEAD" {
 		return
 	}
-	// set gzip writer to response
-	var gzw = gzips.Get().(*gzip.Writer)
-	gzw.Reset(rw.writer)
-	rw.writer = gzw
+	// set compression writer to response
+	var level = rw.policy.level()
+	zw, err := compressWriter(compressor,
+		&countWriter{Writer: rw.writer, count: &rw.encoded}, level)
+	if err != nil {
+		headers.Del("Content-Encoding")
+		return
+	}
+	rw.writer = zw
+	rw.compressor = compressor
+	rw.level = level
 	rw.compressed = true
 }
 
 // Write is responsible for return data in response to the request.
+//
+// If the compression policy sets the minimum size, the data is buffered until
+// it reaches this size to decide whether to compress it.
 func (rw *response) Write(data []byte) (int, error) {
+	if rw.hijacked {
+		return 0, http.ErrHijacked
+	}
 	if !rw.wroteHeader {
-		rw.setDataHeaders(data) // set Content-Type & compression
-		rw.writeHeader()        // real writing header status
+		if threshold := rw.threshold(); threshold > 0 {
+			rw.wroteHeader = true // block rewriting headers
+			rw.buffered = true
+		} else {
+			rw.setDataHeaders(data) // set Content-Type & compression
+			rw.writeHeader()        // real writing header status
+		}
+	}
+	if rw.buffered {
+		rw.buffer = append(rw.buffer, data...)
+		if len(rw.buffer) >= rw.threshold() {
+			if err := rw.flushBuffer(false); err != nil {
+				return 0, err
+			}
+		}
+		return len(data), nil
 	}
 	if rw.request.Method == "HEAD" {
 		return len(data), nil // skip writing on HEAD
@@ -81,31 +165,189 @@ func (rw *response) Write(data []byte) (int, error) {
 	return n, err
 }
 
-// Close terminates the output of the response and frees gzip.Writer if it has
-// been initialized for compression response.
+// threshold returns the size of data buffered before deciding on compression
+// or generating ETag, or zero if the data is not buffered. In the buffered
+// mode it is not less than the mode limit.
+func (rw *response) threshold() int {
+	if rw.autoETag && rw.code == http.StatusOK &&
+		(rw.request.Method == "GET" || rw.request.Method == "HEAD") {
+		return math.MaxInt32 // the whole response is required for ETag
+	}
+	var size = rw.compressThreshold()
+	if rw.bufferLimit > size {
+		return rw.bufferLimit
+	}
+	return size
+}
+
+// compressThreshold returns the size of data buffered before deciding on
+// compression.
+func (rw *response) compressThreshold() int {
+	if rw.policy == nil || rw.policy.MinSize <= 0 || rw.policy.Disabled ||
+		rw.noCompress {
+		return 0
+	}
+	var headers = rw.Header()
+	if headers.Get("Content-Encoding") != "" ||
+		headers.Get("Content-Length") != "" {
+		return 0 // the decision can be made without buffering
+	}
+	return rw.policy.MinSize
+}
+
+// flushBuffer writes the headers and the buffered data to the response. If the
+// response is complete and not compressed, Content-Length is set. In the
+// buffered mode the complete response is compressed in memory, so that
+// Content-Length is set for the compressed response too.
+//
+// For the complete response with automatic ETag, the ETag is generated and
+// the conditional request is evaluated.
+func (rw *response) flushBuffer(complete bool) error {
+	var data = rw.buffer
+	var size = len(data)
+	rw.buffer, rw.buffered = nil, false
+	var compressor = rw.selectCompressor(data) // set Content-Type
+	if complete && rw.autoETag && rw.code == http.StatusOK {
+		rw.generateETag(data, compressor)
+		if notModified(rw.request, rw.Header()) {
+			rw.writeNotModified()
+			return nil
+		}
+	}
+	if compressor != nil && complete && rw.bufferLimit > 0 {
+		data = rw.compressData(compressor, data)
+	} else if compressor != nil {
+		rw.compress(compressor)
+	}
+	if headers := rw.Header(); complete && rw.compressor == nil &&
+		(headers.Get("Content-Encoding") == "" || rw.bufferLimit > 0) &&
+		bodyAllowed(rw.code) {
+		headers.Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	rw.writeHeader() // real writing header status
+	if rw.request.Method == "HEAD" || len(data) == 0 {
+		return nil
+	}
+	n, err := rw.writer.Write(data)
+	if n == len(data) {
+		n = size // uncompressed size
+	}
+	rw.written += int64(n)
+	return err
+}
+
+// compressData returns the compressed data and sets the response headers. If
+// the data cannot be compressed, it is returned as is.
+func (rw *response) compressData(compressor Compressor, data []byte) []byte {
+	var level = rw.policy.level()
+	var buf bytes.Buffer
+	zw, err := compressWriter(compressor, &buf, level)
+	if err != nil {
+		return data
+	}
+	_, err = zw.Write(data)
+	if cerr := releaseCompressWriter(compressor, zw, level); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return data
+	}
+	// remove the header compression support, not to install it again
+	rw.request.Header.Del("Accept-Encoding")
+	rw.Header().Set("Content-Encoding", compressor.Encoding())
+	rw.compressed = true
+	rw.encoded = int64(buf.Len())
+	return buf.Bytes()
+}
+
+// generateETag sets the strong ETag header calculated by the response data,
+// if it was not set by the handler. The compressed representation gets its
+// own ETag.
+func (rw *response) generateETag(data []byte, compressor Compressor) {
+	var headers = rw.Header()
+	if headers.Get("ETag") != "" {
+		return
+	}
+	var sum = sha256.Sum256(data)
+	var etag = base64.RawURLEncoding.EncodeToString(sum[:18])
+	if compressor != nil {
+		etag += "-" + compressor.Encoding()
+	}
+	headers.Set("ETag", `"`+etag+`"`)
+}
+
+// writeNotModified writes the 304 response status without representation
+// headers.
+func (rw *response) writeNotModified() {
+	var headers = rw.Header()
+	headers.Del("Content-Type")
+	headers.Del("Content-Length")
+	headers.Del("Content-Encoding")
+	rw.code = http.StatusNotModified
+	rw.writeHeader()
+}
+
+// bodyAllowed returns true if the response status code permits a body.
+func bodyAllowed(code int) bool {
+	return code >= 200 && code != http.StatusNoContent &&
+		code != http.StatusNotModified
+}
+
+// Close terminates the output of the response and frees the compression
+// writer if it has been initialized for compression response.
 func (rw *response) Close() {
 	if !rw.wroteHeader {
 		rw.code = http.StatusNoContent
 		rw.writeHeader()
 	}
-	if gzw, ok := rw.writer.(*gzip.Writer); ok {
-		gzw.Close()
-		gzips.Put(gzw)
+	if rw.buffered {
+		rw.flushBuffer(true)
+	}
+	if rw.compressor != nil {
+		releaseCompressWriter(rw.compressor, rw.writer.(io.WriteCloser),
+			rw.level)
+		rw.compressor = nil
 	}
 }
 
 // Flush supports the http.Flusher interface.
 func (rw *response) Flush() {
-	if !rw.wroteHeader {
+	if rw.hijacked {
+		return
+	}
+	if rw.buffered {
+		rw.flushBuffer(false)
+	} else if !rw.wroteHeader {
 		rw.writeHeader()
 	}
-	if gzw, ok := rw.writer.(*gzip.Writer); ok {
-		gzw.Flush()
+	if flusher, ok := rw.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
 	}
 	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
 		flusher.Flush()
 	}
 }
 
-// gzips contains pool of gzip.Writer.
-var gzips = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+// Hijack supports the http.Hijacker interface. After hijacking the response
+// is considered written.
+func (rw *response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	rw.hijacked = true
+	rw.wroteHeader = true
+	return conn, brw, nil
+}
+
+// Push supports the http.Pusher interface.
+func (rw *response) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := rw.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"strings"
 	"testing"
 )
 
@@ -166,3 +167,68 @@ func TestResponse(t *testing.T) {
 	fmt.Println(string(dump))
 
 }
+
+func TestResponseThreshold(t *testing.T) {
+	var policy = &CompressionPolicy{MinSize: 100}
+	for _, data := range []struct {
+		chunks   []string
+		encoding string
+		length   string
+	}{
+		{[]string{"<html>", "test", "</html>"}, "", "17"},
+		{[]string{"<html>", strings.Repeat("test ", 100), "</html>"}, "gzip", ""},
+		{[]string{strings.Repeat("<p>test</p>", 20)}, "gzip", ""},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		resp := &response{
+			ResponseWriter: w,
+			code:           http.StatusOK,
+			writer:         w,
+			request:        r,
+			policy:         policy,
+		}
+		for _, chunk := range data.chunks {
+			if _, err := resp.Write([]byte(chunk)); err != nil {
+				t.Error(err)
+			}
+		}
+		if len(strings.Join(data.chunks, "")) < policy.MinSize &&
+			(!resp.buffered || w.Body.Len() != 0) {
+			t.Error("data is written before threshold")
+		}
+		resp.Close()
+		if encoding := w.Header().Get("Content-Encoding"); encoding != data.encoding {
+			t.Errorf("bad encoding: %q", encoding)
+		}
+		if length := w.Header().Get("Content-Length"); length != data.length {
+			t.Errorf("bad content length: %q", length)
+		}
+		if resp.written != int64(len(strings.Join(data.chunks, ""))) {
+			t.Error("bad written size:", resp.written)
+		}
+	}
+
+	// flush forces the decision
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	resp := &response{
+		ResponseWriter: w,
+		code:           http.StatusOK,
+		writer:         w,
+		request:        r,
+		policy:         policy,
+	}
+	resp.Write([]byte("<html>"))
+	resp.Flush()
+	if !w.Flushed || w.Body.String() != "<html>" {
+		t.Error("bad flush of buffered data")
+	}
+	resp.Write([]byte(strings.Repeat("test ", 100)))
+	resp.Close()
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Content-Length") != "" {
+		t.Error("bad headers after flush")
+	}
+}
@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RotateFile is the log file rotated by the size or periodically. The current
+// file is renamed with the rotation time suffix and the new one is created.
+// It is safe for concurrent use.
+type RotateFile struct {
+	Filename   string        // file name
+	MaxSize    int64         // rotate when the size is exceeded (0 - no limit)
+	Interval   time.Duration // rotate on the interval boundaries (0 - never)
+	MaxBackups int           // the number of rotated files kept (0 - all)
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	opened     time.Time
+}
+
+// Write writes the data to the file, rotating it, if needed.
+func (f *RotateFile) Write(data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var now = time.Now()
+	if f.file != nil && ((f.MaxSize > 0 && f.size+int64(len(data)) > f.MaxSize) ||
+		(f.Interval > 0 && !now.Truncate(f.Interval).Equal(f.opened.Truncate(f.Interval)))) {
+		if err := f.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	if f.file == nil {
+		if err := f.open(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file and renames it.
+func (f *RotateFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotate(time.Now())
+}
+
+// Close closes the file.
+func (f *RotateFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	var err = f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the file for appending.
+func (f *RotateFile) open(now time.Time) error {
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, fi.Size(), now
+	if f.Interval > 0 && fi.Size() > 0 {
+		f.opened = fi.ModTime() // continue the period of the existing file
+	}
+	return nil
+}
+
+// rotate renames the current file and removes old backups. Must be called
+// with the lock held.
+func (f *RotateFile) rotate(now time.Time) error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	var backup = f.Filename + "." + now.Format("20060102-150405.000000000")
+	if err := os.Rename(f.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if f.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(f.Filename + ".*")
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups) // the oldest first
+	for len(backups) > f.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+	return nil
+}
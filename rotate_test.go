@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateFile(t *testing.T) {
+	var filename = filepath.Join(t.TempDir(), "access.log")
+	var f = &RotateFile{Filename: filename, MaxSize: 10, MaxBackups: 2}
+	defer f.Close()
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil || string(data) != "line 4\n" {
+		t.Errorf("bad current file: %q %v", data, err)
+	}
+	backups, _ := filepath.Glob(filename + ".*")
+	if len(backups) != 2 {
+		t.Fatal("bad backups:", backups)
+	}
+	if data, _ := os.ReadFile(backups[1]); string(data) != "line 3\n" {
+		t.Errorf("bad last backup: %q", data)
+	}
+
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("line 5\n"))
+	if data, _ := os.ReadFile(filename); !strings.HasPrefix(string(data), "line 5") {
+		t.Errorf("bad file after rotation: %q", data)
+	}
+}
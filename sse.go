@@ -0,0 +1,386 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event describes a server-sent event.
+//
+// The data of the event can be a string or a slice of bytes; all other types
+// are encoded in JSON.
+type Event struct {
+	ID    string        // event identifier (assigned by Broker if empty)
+	Event string        // event type name
+	Data  interface{}   // event data
+	Retry time.Duration // client reconnection time
+}
+
+// encode returns the event in the text/event-stream format.
+func (e *Event) encode() ([]byte, error) {
+	var data []byte
+	switch v := e.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if e.ID != "" {
+		buf.WriteString("id: ")
+		buf.WriteString(strings.Replace(e.ID, "\n", "", -1))
+		buf.WriteByte('\n')
+	}
+	if e.Event != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(strings.Replace(e.Event, "\n", "", -1))
+		buf.WriteByte('\n')
+	}
+	if e.Retry > 0 {
+		buf.WriteString("retry: ")
+		buf.WriteString(strconv.FormatInt(int64(e.Retry/time.Millisecond), 10))
+		buf.WriteByte('\n')
+	}
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte{'\r'}))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// SlowConsumerPolicy defines what the Broker does with the event when the
+// subscriber's queue is full.
+type SlowConsumerPolicy int
+
+// Supported slow consumer policies.
+const (
+	DropNewest SlowConsumerPolicy = iota // skip the new event
+	DropOldest                           // remove the oldest event from queue
+	Disconnect                           // close the subscriber connection
+)
+
+// Broker is an in-process publisher of server-sent events to named topics.
+// The zero value is ready to use.
+//
+// Handlers publish events with the Publish method, and the clients subscribe
+// through the Handler returned by Subscribe. Each subscriber has its own
+// buffered queue. When the queue is full, the event is handled according to
+// the Policy.
+//
+// When the client reconnects with the Last-Event-ID header, the Broker replays
+// the kept recent events published after the event with this identifier.
+// The topic is removed when it has neither subscribers nor kept events.
+type Broker struct {
+	BufferSize int                // subscriber queue size (16 if zero)
+	ReplaySize int                // recent events kept per topic for replay
+	Policy     SlowConsumerPolicy // slow consumer policy
+	KeepAlive  time.Duration      // keep-alive comment interval (if not zero)
+	mu         sync.Mutex
+	topics     map[string]*sseTopic
+	seq        uint64 // last event sequence number
+	published  uint64 // published events counter
+	dropped    uint64 // dropped events counter
+	closed     uint64 // disconnected slow subscribers counter
+}
+
+// sseTopic contains subscribers and recent events of the named topic.
+type sseTopic struct {
+	subscribers map[*sseSubscriber]struct{}
+	history     []*sseMessage
+}
+
+// sseMessage is an encoded event with its sequence number.
+type sseMessage struct {
+	seq  uint64
+	id   string
+	data []byte
+}
+
+// sseSubscriber is a single client subscription to the topics.
+type sseSubscriber struct {
+	topics []string
+	queue  chan *sseMessage
+	replay []*sseMessage
+	done   chan struct{}
+}
+
+// Publish sends the event to all subscribers of the topic. If the event
+// identifier is empty, it is assigned a sequence number.
+func (b *Broker) Publish(topic string, event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	if event.ID == "" {
+		event.ID = strconv.FormatUint(b.seq, 10)
+	}
+	data, err := event.encode()
+	if err != nil {
+		b.seq--
+		return err
+	}
+	var msg = &sseMessage{seq: b.seq, id: event.ID, data: data}
+	b.published++
+	t := b.topic(topic)
+	if b.ReplaySize > 0 {
+		if len(t.history) >= b.ReplaySize {
+			t.history = append(t.history[:0], t.history[len(t.history)-b.ReplaySize+1:]...)
+		}
+		t.history = append(t.history, msg)
+	}
+	for sub := range t.subscribers {
+		b.send(sub, msg)
+	}
+	b.release(topic)
+	return nil
+}
+
+// send puts the message to the subscriber queue with regard to the slow
+// consumer policy. Must be called with the lock held.
+func (b *Broker) send(sub *sseSubscriber, msg *sseMessage) {
+	for {
+		select {
+		case sub.queue <- msg:
+			return
+		default:
+		}
+		switch b.Policy {
+		case DropOldest:
+			select {
+			case <-sub.queue:
+				b.dropped++
+			default:
+			}
+			continue // try again
+		case Disconnect:
+			b.dropped++
+			b.closed++
+			b.remove(sub)
+		default:
+			b.dropped++
+		}
+		return
+	}
+}
+
+// topic returns the named topic, creating it if necessary. Must be called with
+// the lock held.
+func (b *Broker) topic(name string) *sseTopic {
+	if b.topics == nil {
+		b.topics = make(map[string]*sseTopic)
+	}
+	t := b.topics[name]
+	if t == nil {
+		t = &sseTopic{subscribers: make(map[*sseSubscriber]struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// release removes the named topic without subscribers and kept events, so the
+// topics requested by the clients do not accumulate. Must be called with the
+// lock held.
+func (b *Broker) release(name string) {
+	if t := b.topics[name]; t != nil &&
+		len(t.subscribers) == 0 && len(t.history) == 0 {
+		delete(b.topics, name)
+	}
+}
+
+// subscribe registers a new subscriber to the topics. The events published
+// after lastEventID are prepared for replay.
+func (b *Broker) subscribe(topics []string, lastEventID string) *sseSubscriber {
+	var size = b.BufferSize
+	if size <= 0 {
+		size = 16
+	}
+	var sub = &sseSubscriber{
+		topics: topics,
+		queue:  make(chan *sseMessage, size),
+		done:   make(chan struct{}),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, name := range topics {
+		b.topic(name).subscribers[sub] = struct{}{}
+	}
+	if lastEventID != "" {
+		sub.replay = b.replay(topics, lastEventID)
+	}
+	return sub
+}
+
+// replay returns the kept events of topics published after the event with the
+// specified identifier, ordered by publication. Must be called with the lock
+// held.
+func (b *Broker) replay(topics []string, lastEventID string) []*sseMessage {
+	var last, found = uint64(0), false
+	for _, name := range topics {
+		for _, msg := range b.topics[name].history {
+			if msg.id == lastEventID {
+				last, found = msg.seq, true
+			}
+		}
+	}
+	if !found {
+		var err error
+		if last, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			return nil
+		}
+	}
+	var list []*sseMessage
+	for _, name := range topics {
+		for _, msg := range b.topics[name].history {
+			if msg.seq > last {
+				list = append(list, msg)
+			}
+		}
+	}
+	// insertion sort: the histories are already sorted
+	for i := 1; i < len(list); i++ {
+		for j := i; j > 0 && list[j].seq < list[j-1].seq; j-- {
+			list[j], list[j-1] = list[j-1], list[j]
+		}
+	}
+	return list
+}
+
+// remove unsubscribes the subscriber from all topics and closes it. Must be
+// called with the lock held.
+func (b *Broker) remove(sub *sseSubscriber) {
+	for _, name := range sub.topics {
+		if t := b.topics[name]; t != nil {
+			delete(t.subscribers, sub)
+			b.release(name)
+		}
+	}
+	select {
+	case <-sub.done:
+	default:
+		close(sub.done)
+	}
+}
+
+// unsubscribe removes the subscriber.
+func (b *Broker) unsubscribe(sub *sseSubscriber) {
+	b.mu.Lock()
+	b.remove(sub)
+	b.mu.Unlock()
+}
+
+// Subscribe returns the Handler streaming the events of the specified topics
+// to the client. If topics are not specified, they are taken from the "topic"
+// query parameters of the request.
+func (b *Broker) Subscribe(topics ...string) Handler {
+	return func(c *Context) error {
+		var names = topics
+		if len(names) == 0 {
+			names = c.Request.URL.Query()["topic"]
+			if len(names) == 0 {
+				return ErrBadRequest
+			}
+		}
+		var sub = b.subscribe(names, c.Header("Last-Event-ID"))
+		defer b.unsubscribe(sub)
+
+		c.SetContentType("text/event-stream")
+		c.SetHeader("Cache-Control", "no-cache")
+		c.SetHeader("X-Accel-Buffering", "no") // disable nginx buffering
+		var flusher = c.Response.(*response)
+		flusher.Flush() // send headers
+		for _, msg := range sub.replay {
+			if _, err := c.Response.Write(msg.data); err != nil {
+				return nil
+			}
+		}
+		flusher.Flush()
+
+		var keepAlive <-chan time.Time
+		if b.KeepAlive > 0 {
+			ticker := time.NewTicker(b.KeepAlive)
+			defer ticker.Stop()
+			keepAlive = ticker.C
+		}
+		for {
+			var err error
+			select {
+			case msg := <-sub.queue:
+				_, err = c.Response.Write(msg.data)
+			case <-keepAlive:
+				_, err = c.Response.Write([]byte(":\n\n"))
+			case <-sub.done:
+				return nil
+			case <-c.Request.Context().Done():
+				return nil
+			}
+			if err != nil {
+				return nil // the client is gone
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Subscribers returns the number of subscribers of the topic.
+func (b *Broker) Subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t := b.topics[topic]; t != nil {
+		return len(t.subscribers)
+	}
+	return 0
+}
+
+// BrokerStats contains the Broker metrics.
+type BrokerStats struct {
+	Topics       map[string]int `json:"topics"`       // subscribers by topic
+	Subscribers  int            `json:"subscribers"`  // unique subscribers
+	Published    uint64         `json:"published"`    // published events
+	Dropped      uint64         `json:"dropped"`      // events dropped for slow consumers
+	Disconnected uint64         `json:"disconnected"` // disconnected slow consumers
+}
+
+// Stats returns the current Broker metrics.
+func (b *Broker) Stats() BrokerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var (
+		stats = BrokerStats{
+			Topics:       make(map[string]int, len(b.topics)),
+			Published:    b.published,
+			Dropped:      b.dropped,
+			Disconnected: b.closed,
+		}
+		unique = make(map[*sseSubscriber]struct{})
+	)
+	for name, t := range b.topics {
+		stats.Topics[name] = len(t.subscribers)
+		for sub := range t.subscribers {
+			unique[sub] = struct{}{}
+		}
+	}
+	stats.Subscribers = len(unique)
+	return stats
+}
+
+// Close disconnects all subscribers.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, t := range b.topics {
+		for sub := range t.subscribers {
+			b.remove(sub)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent(t *testing.T) {
+	event := &Event{
+		ID:    "1",
+		Event: "update",
+		Data:  "line1\nline2",
+		Retry: time.Second,
+	}
+	data, err := event.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "id: 1\nevent: update\nretry: 1000\ndata: line1\ndata: line2\n\n" {
+		t.Errorf("bad event encoding: %q", data)
+	}
+	data, err = (&Event{Data: JSON{"a": 1}}).encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data: {\"a\":1}\n\n" {
+		t.Errorf("bad json event encoding: %q", data)
+	}
+	if _, err = (&Event{Data: func() {}}).encode(); err == nil {
+		t.Error("expected encoding error")
+	}
+}
+
+func TestBroker(t *testing.T) {
+	var broker = &Broker{ReplaySize: 2}
+	var ts = httptest.NewServer(broker.Subscribe())
+	defer ts.Close()
+	defer broker.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := broker.Publish("news", Event{Data: "old"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, _ := http.NewRequest("GET", ts.URL+"/?topic=news&topic=alerts&topic=empty", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Error("bad content type:", ct)
+	}
+	for broker.Subscribers("news") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := broker.Stats(); stats.Subscribers != 1 ||
+		stats.Topics["alerts"] != 1 || stats.Published != 3 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	broker.Publish("alerts", Event{Event: "alert", Data: "new"})
+
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for len(ids) < 3 && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "id: ") {
+			ids = append(ids, line[4:])
+		}
+	}
+	// event 1 is outside of the replay window, so replay starts from 2
+	if strings.Join(ids, ",") != "2,3,4" {
+		t.Error("bad event ids:", ids)
+	}
+
+	if _, err := http.Get(ts.URL); err != nil {
+		t.Error(err)
+	}
+	resp.Body.Close()
+	for broker.Subscribers("news") != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// the topic without subscribers and kept events is removed
+	if _, ok := broker.Stats().Topics["empty"]; ok {
+		t.Error("empty topic is not removed")
+	}
+	if _, ok := broker.Stats().Topics["alerts"]; !ok {
+		t.Error("topic with kept events is removed")
+	}
+}
+
+func TestBrokerSlowConsumer(t *testing.T) {
+	for _, policy := range []SlowConsumerPolicy{DropNewest, DropOldest, Disconnect} {
+		var broker = &Broker{BufferSize: 1, Policy: policy}
+		sub := broker.subscribe([]string{"test"}, "")
+		broker.Publish("test", Event{Data: "1"})
+		broker.Publish("test", Event{Data: "2"})
+		stats := broker.Stats()
+		if stats.Dropped != 1 {
+			t.Error("bad dropped counter:", policy, stats.Dropped)
+		}
+		switch policy {
+		case DropNewest:
+			if msg := <-sub.queue; msg.id != "1" {
+				t.Error("bad drop newest:", msg.id)
+			}
+		case DropOldest:
+			if msg := <-sub.queue; msg.id != "2" {
+				t.Error("bad drop oldest:", msg.id)
+			}
+		case Disconnect:
+			select {
+			case <-sub.done:
+			default:
+				t.Error("slow consumer not disconnected")
+			}
+			if stats.Disconnected != 1 || broker.Subscribers("test") != 0 {
+				t.Errorf("bad disconnect stats: %+v", stats)
+			}
+		}
+		broker.unsubscribe(sub)
+	}
+}
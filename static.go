@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultFingerprint matches the file names with the content hash, like
+// "app.3f2a9c1b.js" or "style-0123456789abcdef.css".
+var DefaultFingerprint = regexp.MustCompile(`[.-][0-9a-f]{8,}\.[0-9A-Za-z]+$`)
+
+// StaticFiles serves static files from the file system, including embed.FS.
+// The file name is set in the way as the last named parameter or, if there
+// are no parameters, by the request path.
+//
+// Directories are served by their index files or, if Listing is enabled, by
+// the listing as in DirListing. The files with names beginning with a dot are
+// not served, if not allowed. Precompressed files are served as in Files.
+//
+// Use the Handle method as the Handler:
+//
+//	static := &rest.StaticFiles{FS: assets, SPA: true}
+//	mux.Handle("GET", "/*filename", static.Handle)
+type StaticFiles struct {
+	FS            fs.FS          // file system
+	Index         []string       // index files ("index.html" if empty)
+	SPA           bool           // serve index for unknown non-asset paths
+	CacheControl  string         // Cache-Control for not fingerprinted files
+	MaxAge        time.Duration  // max-age of fingerprinted files (a year if 0)
+	Fingerprint   *regexp.Regexp // fingerprinted names (DefaultFingerprint if nil)
+	AllowDotfiles bool           // allow access to dotfiles
+	Listing       bool           // list directories without index files
+}
+
+// Handle serves the requested file.
+func (s *StaticFiles) Handle(c *Context) error {
+	var name = c.Request.URL.Path
+	if len(c.params) > 0 {
+		name = c.params[len(c.params)-1].Value
+	}
+	name = path.Clean("/" + name)
+	if !s.AllowDotfiles && isDotfile(name) {
+		return ErrNotFound
+	}
+	if s.Listing {
+		DirListing(c)
+	}
+	var files = http.FS(s.FS)
+	filename, err := s.resolve(files, name)
+	if err != nil {
+		if !s.SPA || path.Ext(name) != "" ||
+			!errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		// single page application handles this path itself
+		filename = "/" + s.index()[0]
+		c.SetHeader("Cache-Control", "no-cache")
+	} else if s.fingerprinted(filename) {
+		var maxAge = s.MaxAge
+		if maxAge <= 0 {
+			maxAge = 365 * 24 * time.Hour
+		}
+		c.SetCacheControl(CacheControl{
+			Public: true, MaxAge: maxAge, Immutable: true})
+	} else if s.CacheControl != "" {
+		c.SetHeader("Cache-Control", s.CacheControl)
+	}
+	return c.serveFile(files, filename)
+}
+
+// index returns the list of index file names.
+func (s *StaticFiles) index() []string {
+	if len(s.Index) == 0 {
+		return []string{"index.html"}
+	}
+	return s.Index
+}
+
+// resolve returns the name of the file to serve: for directories it is their
+// index file or the directory itself if listing is enabled.
+func (s *StaticFiles) resolve(files http.FileSystem, name string) (string, error) {
+	file, err := files.Open(name)
+	if err != nil {
+		return "", err
+	}
+	fi, err := file.Stat()
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return name, nil
+	}
+	for _, index := range s.index() {
+		var filename = path.Join(name, index)
+		file, err := files.Open(filename)
+		if err != nil {
+			continue
+		}
+		fi, err := file.Stat()
+		file.Close()
+		if err == nil && fi.Mode().IsRegular() {
+			return filename, nil
+		}
+	}
+	if s.Listing {
+		return name, nil
+	}
+	return "", fs.ErrNotExist
+}
+
+// fingerprinted returns true if the file name contains the content hash.
+func (s *StaticFiles) fingerprinted(name string) bool {
+	var fingerprint = s.Fingerprint
+	if fingerprint == nil {
+		fingerprint = DefaultFingerprint
+	}
+	return fingerprint.MatchString(path.Base(name))
+}
+
+// isDotfile returns true if any element of the path begins with a dot.
+func isDotfile(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticFiles(t *testing.T) {
+	var static = &StaticFiles{
+		FS: fstest.MapFS{
+			"index.html":             {Data: []byte("<html>index</html>")},
+			"app.3f2a9c1b.js":        {Data: []byte("alert(1)")},
+			"docs/default.htm":       {Data: []byte("<html>docs</html>")},
+			"empty/readme.txt":       {Data: []byte("readme")},
+			".env":                   {Data: []byte("SECRET=1")},
+			".well-known/config.txt": {Data: []byte("config")},
+		},
+		Index:        []string{"index.html", "default.htm"},
+		SPA:          true,
+		CacheControl: "no-cache",
+	}
+	var mux = new(ServeMux)
+	mux.Handle("GET", "/*filename", static.Handle)
+	for _, test := range []struct {
+		path, body, cacheControl string
+		code                     int
+	}{
+		{"/", "<html>index</html>", "no-cache", 200},
+		{"/app.3f2a9c1b.js", "alert(1)", "public, max-age=31536000, immutable", 200},
+		{"/docs/", "<html>docs</html>", "no-cache", 200},
+		{"/users/123", "<html>index</html>", "no-cache", 200},
+		{"/missing.js", "", "", 404},
+		{"/empty", "<html>index</html>", "no-cache", 200},
+		{"/.env", "", "", 404},
+		{"/.well-known/config.txt", "", "", 404},
+		{"/docs/../.env", "", "", 404},
+	} {
+		r := httptest.NewRequest("GET", test.path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("%s: bad status: %d", test.path, w.Code)
+			continue
+		}
+		if test.code != 200 {
+			continue
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s: bad body: %q", test.path, w.Body.String())
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != test.cacheControl {
+			t.Errorf("%s: bad cache control: %q", test.path, cc)
+		}
+	}
+
+	// without named parameters the request path is used
+	static.AllowDotfiles = true
+	static.SPA = false
+	w := httptest.NewRecorder()
+	static.Handle(newContext(w, httptest.NewRequest("GET", "/.env", nil)))
+	if w.Body.String() != "SECRET=1" {
+		t.Error("bad dotfile access:", w.Body.String())
+	}
+	var c = newContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+	if err := static.Handle(c); err == nil {
+		t.Error("expected not found error")
+	}
+}
@@ -0,0 +1,83 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// goroutinePanic is the panic value of the handler executed in the separate
+// goroutine with the stack trace of this goroutine.
+type goroutinePanic struct {
+	value interface{}
+	stack []byte
+}
+
+// Timeout returns the Handler limiting the execution time of the handlers.
+// The same can be set for all routes with ServeMux.Timeout and overridden for
+// the route with ServeMux.HandleTimeout.
+//
+// The handlers are executed with the deadline of the request context (see
+// Context.Done), and their response is held until they return. If they exceed
+// the timeout, ErrServiceUnavailable is returned and the response is
+// discarded, so the late writes of the abandoned handler do not reach the
+// client. The error context.DeadlineExceeded returned by the handler itself
+// replies with 504 Gateway Timeout. Timeouts are marked in the access log with
+// the "timeout" field.
+//
+// Because of the buffering, the handler cannot stream the response or upgrade
+// the connection to WebSocket.
+func Timeout(timeout time.Duration, handlers ...Handler) Handler {
+	var h = Handlers(handlers...)
+	return func(c *Context) error {
+		if timeout <= 0 {
+			return h(c)
+		}
+		var cancel = c.WithTimeout(timeout)
+		defer cancel()
+		type result struct {
+			err   error
+			panic interface{}
+		}
+		var (
+			sub, rec = c.recording()
+			done     = make(chan result, 1)
+		)
+		// the abandoned handler must not share the request headers and the
+		// data with the request goroutine
+		sub.Request = c.Request.Clone(c.Request.Context())
+		sub.Response.(*response).request = sub.Request
+		sub.data = make(map[interface{}]interface{}, len(c.data))
+		for key, value := range c.data {
+			sub.data[key] = value
+		}
+		go func() {
+			defer func() {
+				if p := recover(); p == http.ErrAbortHandler {
+					done <- result{panic: p} // no stack needed to abort
+				} else if p != nil {
+					done <- result{panic: &goroutinePanic{p, debug.Stack()}}
+				}
+			}()
+			done <- result{err: h(sub)}
+		}()
+		select {
+		case result := <-done:
+			c.logFields, c.route, c.data = sub.logFields, sub.route, sub.data
+			if result.panic != nil {
+				panic(result.panic) // in the request goroutine
+			}
+			if err := rec.result(sub, result.err).writeTo(c); err != nil {
+				return err
+			}
+			return result.err
+		case <-c.Done():
+			if c.Err() != context.DeadlineExceeded {
+				return c.Err() // the request is canceled
+			}
+			c.AddLogField("timeout", timeout)
+			return ErrServiceUnavailable
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	var (
+		mux  = &ServeMux{Timeout: 20 * time.Millisecond}
+		late = make(chan error, 1)
+	)
+	mux.Handle("GET", "/", func(c *Context) error {
+		return c.Write("OK")
+	})
+	mux.Handle("GET", "/slow", func(c *Context) error {
+		c.SetHeader("X-Test", "slow")
+		c.Write("partial")
+		<-c.Done()
+		time.Sleep(10 * time.Millisecond)
+		late <- c.Write("late")
+		return nil
+	})
+	mux.Handle("GET", "/deadline", Timeout(time.Millisecond, func(c *Context) error {
+		<-c.Done()
+		return c.Err()
+	}))
+	mux.Handle("GET", "/route", Timeout(time.Second, func(c *Context) error {
+		if _, ok := c.Deadline(); !ok {
+			t.Error("deadline is not set")
+		}
+		return ErrNotFound
+	}))
+
+	var request = func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if w := request("/"); w.Code != 200 || w.Body.String() != "OK" {
+		t.Error("bad response:", w.Code, w.Body.String())
+	}
+	w := request("/slow")
+	if w.Code != 503 || w.Header().Get("X-Test") != "" {
+		t.Error("bad timeout response:", w.Code, w.Header())
+	}
+	select {
+	case err := <-late:
+		if err != ErrMultipleResponse {
+			t.Error("bad late write error:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler is not completed")
+	}
+	if w.Body.Len() == 0 || w.Header().Get("X-Test") != "" {
+		t.Error("late write reached the client")
+	}
+	if w = request("/deadline"); w.Code != 503 && w.Code != 504 {
+		t.Error("bad deadline response:", w.Code)
+	}
+	if w = request("/route"); w.Code != 404 {
+		t.Error("bad route response:", w.Code)
+	}
+
+	// deadline error without timeout handler
+	mux = new(ServeMux)
+	mux.Handle("GET", "/", func(c *Context) error {
+		return context.DeadlineExceeded
+	})
+	if w = request("/"); w.Code != 504 {
+		t.Error("bad deadline exceeded response:", w.Code)
+	}
+}
+
+func TestTimeout_Abandoned(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		header = make(chan string, 1)
+		mux    = &ServeMux{
+			Timeout:   20 * time.Millisecond,
+			AccessLog: &AccessLog{Writer: &buf, Format: `{{.Route}} {{.Status}}`},
+		}
+	)
+	mux.Handle("GET", "/slow/:id", func(c *Context) error {
+		<-c.Done()
+		time.Sleep(10 * time.Millisecond)
+		header <- c.Header("Accept-Encoding")
+		return nil
+	})
+	r := httptest.NewRequest("GET", "/slow/1", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != 503 || buf.String() != "/slow/:id 503\n" {
+		t.Error("bad timeout response:", w.Code, buf.String())
+	}
+	select {
+	case value := <-header:
+		if value != "gzip" {
+			t.Error("request header is changed after timeout:", value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler is not completed")
+	}
+}
+
+func TestTimeout_Panic(t *testing.T) {
+	defer func() {
+		if p, ok := recover().(*goroutinePanic); !ok || p.value != "test panic" {
+			t.Error("bad panic:", p)
+		}
+	}()
+	var h = Timeout(time.Second, func(c *Context) error {
+		panic("test panic")
+	})
+	h(newContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil)))
+}
+
+func TestServeMux_HandleTimeout(t *testing.T) {
+	var (
+		broker = new(Broker)
+		mux    = &ServeMux{Timeout: 20 * time.Millisecond}
+	)
+	defer broker.Close()
+	var slow = func(c *Context) error {
+		time.Sleep(50 * time.Millisecond)
+		return c.Write("OK")
+	}
+	mux.Handle("GET", "/slow", slow)
+	mux.HandleTimeout(time.Second, "GET", "/long", slow)
+	mux.HandleTimeout(0, "GET", "/events", broker.Subscribe("news"))
+	mux.HandleTimeout(0, "GET", "/ws", func(c *Context) error {
+		ws, err := c.Upgrade()
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return nil
+		}
+		return ws.WriteMessage(TextMessage, data)
+	})
+	var ts = httptest.NewServer(mux)
+	defer ts.Close()
+
+	for path, code := range map[string]int{"/slow": 503, "/long": 200} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Errorf("%s: bad status: %d", path, resp.StatusCode)
+		}
+	}
+
+	// streaming is not limited by the timeout of ServeMux
+	resp, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	for broker.Subscribers("news") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	broker.Publish("news", Event{Data: "event"})
+	var scanner = bufio.NewScanner(resp.Body)
+	for scanner.Scan() && scanner.Text() != "data: event" {
+	}
+	if resp.StatusCode != 200 || scanner.Text() != "data: event" {
+		t.Error("bad event stream:", resp.Status, scanner.Err())
+	}
+
+	// websocket upgrade
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	var reader = bufio.NewReader(conn)
+	if resp, err := http.ReadResponse(reader, nil); err != nil ||
+		resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal("bad upgrade:", resp, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	conn.Write(wsClientFrame(true, TextMessage, []byte("hello")))
+	if opcode, data := wsReadFrame(t, reader); opcode != TextMessage || string(data) != "hello" {
+		t.Error("bad websocket message:", opcode, string(data))
+	}
+}
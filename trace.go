@@ -0,0 +1,241 @@
+package rest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID is the W3C Trace Context trace identifier.
+type TraceID [16]byte
+
+// String returns the hex encoded trace ID.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid returns true if the trace ID is not zero.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID is the W3C Trace Context span identifier.
+type SpanID [8]byte
+
+// String returns the hex encoded span ID.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid returns true if the span ID is not zero.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext identifies the span in the trace and is propagated with the
+// traceparent and tracestate headers.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool   // the trace is recorded
+	TraceState string // vendor-specific trace state
+}
+
+// ParseTraceParent parses the traceparent header value. It returns false if
+// the value is invalid.
+func ParseTraceParent(value string) (SpanContext, bool) {
+	var sc SpanContext
+	value = strings.TrimSpace(value)
+	// version-traceid-spanid-flags
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, false
+	}
+	var version, flags [1]byte
+	if _, err := hex.Decode(version[:], []byte(value[:2])); err != nil ||
+		version[0] == 0xff || (version[0] == 0 && len(value) != 55) ||
+		(len(value) > 55 && value[55] != '-') {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(value[3:35])); err != nil ||
+		!sc.TraceID.IsValid() || strings.ToLower(value) != value {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(value[36:52])); err != nil ||
+		!sc.SpanID.IsValid() {
+		return sc, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(value[53:55])); err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, true
+}
+
+// TraceParent returns the traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	var flags = "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// Span describes the operation in the trace.
+type Span struct {
+	Name       string
+	Context    SpanContext
+	Parent     SpanID // the parent span (zero for the root)
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	exporter   Exporter
+	mu         sync.Mutex
+	ended      bool
+}
+
+// newSpan returns the started span with the new span ID.
+func newSpan(name string, parent SpanContext, exporter Exporter) *Span {
+	var span = &Span{
+		Name:       name,
+		Context:    parent,
+		Parent:     parent.SpanID,
+		Start:      time.Now(),
+		Attributes: make(map[string]interface{}),
+		exporter:   exporter,
+	}
+	if !parent.TraceID.IsValid() {
+		rand.Read(span.Context.TraceID[:])
+		span.Context.Sampled = true
+	}
+	rand.Read(span.Context.SpanID[:])
+	return span
+}
+
+// StartChild starts the child span.
+func (s *Span) StartChild(name string) *Span {
+	return newSpan(name, s.Context, s.exporter)
+}
+
+// SetAttribute sets the attribute of the span.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	s.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// Finish ends the span and exports it, if the trace is sampled. The
+// subsequent calls are ignored.
+func (s *Span) Finish() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended, s.End = true, time.Now()
+	s.mu.Unlock()
+	if s.exporter != nil && s.Context.Sampled {
+		s.exporter.Export(s)
+	}
+}
+
+// Duration returns the duration of the finished span.
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Inject sets the traceparent and tracestate headers of the outgoing request
+// to continue the trace with the span as the parent.
+func (s *Span) Inject(header http.Header) {
+	header.Set("traceparent", s.Context.TraceParent())
+	if s.Context.TraceState != "" {
+		header.Set("tracestate", s.Context.TraceState)
+	}
+}
+
+// Exporter exports finished spans.
+type Exporter interface {
+	Export(span *Span)
+}
+
+// MemoryExporter collects the exported spans in memory. It is used in tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// Export saves the span.
+func (e *MemoryExporter) Export(span *Span) {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+}
+
+// Spans returns the exported spans.
+func (e *MemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span(nil), e.spans...)
+}
+
+// Reset removes the exported spans.
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// Tracer creates the span for each request of ServeMux, continuing the trace
+// from the traceparent and tracestate request headers. The span is named by
+// the method and the route pattern and has the attributes "http.method",
+// "http.route", "http.target", "http.status_code", "request.id" and "error"
+// if the handler failed. Finished spans of sampled traces are passed to the
+// Exporter.
+//
+// The span of the request is available with Context.Span or SpanFromContext
+// for child spans and propagation to outgoing calls:
+//
+//	span := c.Span().StartChild("query")
+//	defer span.Finish()
+type Tracer struct {
+	Exporter Exporter // spans exporter
+}
+
+// spanKey is the request context key of the span.
+type spanKey struct{}
+
+// start starts the span of the request.
+func (t *Tracer) start(c *Context) *Span {
+	var parent, _ = ParseTraceParent(c.Header("traceparent"))
+	if parent.TraceID.IsValid() {
+		parent.TraceState = strings.TrimSpace(c.Header("tracestate"))
+	}
+	var span = newSpan(c.Request.Method, parent, t.Exporter)
+	c.span = span
+	c.WithValue(spanKey{}, span)
+	return span
+}
+
+// finish ends the span of the completed request.
+func (t *Tracer) finish(c *Context, span *Span, err error) {
+	if c.route != "" {
+		span.Name = c.Request.Method + " " + c.route
+		span.SetAttribute("http.route", c.route)
+	}
+	span.SetAttribute("http.method", c.Request.Method)
+	span.SetAttribute("http.target", c.Request.URL.RequestURI())
+	span.SetAttribute("http.status_code", c.Status())
+	if id := c.RequestID(); id != "" {
+		span.SetAttribute("request.id", id)
+	}
+	if err != nil {
+		span.SetAttribute("error", err.Error())
+	}
+	span.Finish()
+}
+
+// Span returns the span of the request created by ServeMux.Tracer or nil.
+func (c *Context) Span() *Span {
+	return c.span
+}
+
+// SpanFromContext returns the span of the request from the context or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
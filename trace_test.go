@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	for _, test := range []struct {
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, false},
+		{"", false, false},
+	} {
+		sc, ok := ParseTraceParent(test.value)
+		if ok != test.valid || sc.Sampled != test.sampled {
+			t.Errorf("bad parse of %q: %v %v", test.value, ok, sc.Sampled)
+		}
+		if ok && test.value[:2] == "00" && sc.TraceParent() != test.value {
+			t.Errorf("bad traceparent: %s", sc.TraceParent())
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var (
+		exporter = new(MemoryExporter)
+		mux      = &ServeMux{Tracer: &Tracer{Exporter: exporter}}
+		outgoing = make(http.Header)
+	)
+	mux.Handle("GET", "/user/:name", func(c *Context) error {
+		if SpanFromContext(c.Request.Context()) != c.Span() {
+			t.Error("no span in request context")
+		}
+		var child = c.Span().StartChild("query")
+		child.SetAttribute("db", "test")
+		child.Inject(outgoing)
+		child.Finish()
+		child.Finish()
+		return ErrNotFound
+	})
+
+	r := httptest.NewRequest("GET", "/user/test?q=1", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set("tracestate", "vendor=value")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+
+	var spans = exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatal("bad spans count:", len(spans))
+	}
+	var child, span = spans[0], spans[1]
+	if span.Name != "GET /user/:name" ||
+		span.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		span.Parent.String() != "00f067aa0ba902b7" ||
+		span.Context.TraceState != "vendor=value" ||
+		span.Attributes["http.route"] != "/user/:name" ||
+		span.Attributes["http.target"] != "/user/test?q=1" ||
+		span.Attributes["http.status_code"] != 404 ||
+		span.Attributes["error"] != "not found" || span.Duration() <= 0 {
+		t.Errorf("bad request span: %+v", span)
+	}
+	if child.Name != "query" || child.Parent != span.Context.SpanID ||
+		child.Context.TraceID != span.Context.TraceID || child.Attributes["db"] != "test" {
+		t.Errorf("bad child span: %+v", child)
+	}
+	if outgoing.Get("traceparent") != child.Context.TraceParent() ||
+		outgoing.Get("tracestate") != "vendor=value" {
+		t.Error("bad outgoing headers:", outgoing)
+	}
+
+	// new trace and not sampled trace
+	exporter.Reset()
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/user/test", nil))
+	r = httptest.NewRequest("GET", "/user/test", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	if spans = exporter.Spans(); len(spans) != 2 || spans[1].Parent.IsValid() ||
+		!spans[1].Context.TraceID.IsValid() {
+		t.Error("bad new trace spans:", len(spans))
+	}
+}
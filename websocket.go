@@ -0,0 +1,538 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket message types defined in RFC 6455.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// WebSocket close codes defined in RFC 6455, section 7.4.1.
+const (
+	CloseNormalClosure      = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatusReceived   = 1005
+	CloseAbnormalClosure    = 1006
+	CloseInvalidPayloadData = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseMandatoryExtension = 1010
+	CloseInternalServerErr  = 1011
+)
+
+// DefaultMaxMessageSize is used as the WebSocket message size limit if
+// another is not specified.
+const DefaultMaxMessageSize = 1 << 20
+
+// Errors returned by the WebSocket handshake.
+var (
+	ErrBadHandshake    = &Error{400, "bad websocket handshake"}
+	ErrUpgradeRequired = &Error{426, "upgrade required"}
+)
+
+// CloseError is returned when the WebSocket connection is closed.
+type CloseError struct {
+	Code int    `json:"code"`
+	Text string `json:"text,omitempty"`
+}
+
+// Error returns a textual description of the close reason.
+func (e *CloseError) Error() string {
+	var msg = "websocket: close " + strconv.Itoa(e.Code)
+	if e.Text != "" {
+		msg += ": " + e.Text
+	}
+	return msg
+}
+
+// websocketGUID is used to calculate Sec-WebSocket-Accept.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket is the server side of the WebSocket connection.
+//
+// Ping messages are automatically answered by ReadMessage and pong messages
+// are skipped. Fragmented messages are assembled into one.
+//
+// Only one goroutine can read messages at the same time. Write methods may be
+// called concurrently.
+type WebSocket struct {
+	MaxMessageSize int64   // message size limit (DefaultMaxMessageSize if 0)
+	Encoder        Encoder // data Encoder used by WriteJSON
+	Protocol       string  // selected subprotocol
+	request        *http.Request
+	conn           net.Conn
+	reader         *bufio.Reader
+	msgMu          sync.Mutex // locks the message writing
+	frameMu        sync.Mutex // locks the frame writing
+	closeSent      bool
+}
+
+// OriginChecker returns true if the WebSocket connection is allowed for the
+// origin of the request.
+type OriginChecker func(r *http.Request) bool
+
+// Upgrade upgrades the HTTP connection to the WebSocket protocol. If protocols
+// are specified, the first of them also supported by the client is selected.
+//
+// The browser requests from other origins are rejected with ErrForbidden to
+// prevent cross-site WebSocket hijacking. Another check can be set with
+// ServeMux.CheckOrigin:
+//
+//	mux.CheckOrigin = func(r *http.Request) bool {
+//		return r.Header.Get("Origin") == "https://example.com"
+//	}
+//
+// After a successful upgrade the response is considered written and the
+// connection must be closed by the handler.
+func (c *Context) Upgrade(protocols ...string) (*WebSocket, error) {
+	if c.IsWrote() {
+		return nil, ErrMultipleResponse
+	}
+	if c.Request.Method != "GET" ||
+		!headerContains(c.Request.Header, "Connection", "upgrade") ||
+		!headerContains(c.Request.Header, "Upgrade", "websocket") {
+		c.SetHeader("Upgrade", "websocket")
+		return nil, ErrUpgradeRequired
+	}
+	if c.Header("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, ErrUpgradeRequired
+	}
+	var checkOrigin = c.checkOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(c.Request) {
+		return nil, ErrForbidden
+	}
+	var key = c.Header("Sec-WebSocket-Key")
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil ||
+		len(nonce) != 16 {
+		return nil, ErrBadHandshake
+	}
+	var ws = &WebSocket{
+		Encoder: c.Encoder,
+		request: c.Request,
+	}
+	// select subprotocol
+selectProtocol:
+	for _, offer := range headerTokens(c.Request.Header, "Sec-WebSocket-Protocol") {
+		for _, protocol := range protocols {
+			if offer == protocol {
+				ws.Protocol = protocol
+				break selectProtocol
+			}
+		}
+	}
+	var resp = c.Response.(*response)
+	resp.WriteHeader(http.StatusSwitchingProtocols)
+	conn, brw, err := resp.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	ws.conn = conn
+	ws.reader = brw.Reader
+	// writing handshake response
+	var sum = sha1.Sum([]byte(key + websocketGUID))
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: ")
+	brw.WriteString(base64.StdEncoding.EncodeToString(sum[:]))
+	brw.WriteString("\r\n")
+	if ws.Protocol != "" {
+		brw.WriteString("Sec-WebSocket-Protocol: " + ws.Protocol + "\r\n")
+	}
+	for key, values := range c.Response.Header() {
+		if strings.HasPrefix(key, "Content-") {
+			continue
+		}
+		for _, value := range values {
+			brw.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// headerContains returns true if the comma-separated list of header values
+// contains the token (case insensitive).
+func headerContains(header http.Header, key, token string) bool {
+	for _, value := range headerTokens(header, key) {
+		if strings.EqualFold(value, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin returns true if the request has no Origin header (not a browser)
+// or its host matches the host of the request.
+func sameOrigin(r *http.Request) bool {
+	var origin = r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// headerTokens returns the list of comma-separated header values.
+func headerTokens(header http.Header, key string) []string {
+	var list []string
+	for _, value := range header[http.CanonicalHeaderKey(key)] {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				list = append(list, token)
+			}
+		}
+	}
+	return list
+}
+
+// Request returns the original http request of the connection.
+func (ws *WebSocket) Request() *http.Request {
+	return ws.request
+}
+
+// SetReadDeadline sets the deadline for future read calls.
+func (ws *WebSocket) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for future write calls.
+func (ws *WebSocket) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// ReadMessage reads the next data message from the connection. If the close
+// message was received, *CloseError is returned.
+func (ws *WebSocket) ReadMessage() (messageType int, data []byte, err error) {
+	var limit = ws.MaxMessageSize
+	if limit <= 0 {
+		limit = DefaultMaxMessageSize
+	}
+	for {
+		fin, opcode, payload, err := ws.readFrame(limit - int64(len(data)))
+		if err != nil {
+			return 0, nil, ws.fail(err)
+		}
+		switch opcode {
+		case PingMessage:
+			if err := ws.writeFrame(true, PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			var closeErr = &CloseError{Code: CloseNoStatusReceived}
+			if len(payload) > 0 {
+				if len(payload) >= 2 {
+					closeErr.Code = int(binary.BigEndian.Uint16(payload))
+					closeErr.Text = string(payload[2:])
+				}
+				if len(payload) == 1 || !validCloseCode(closeErr.Code) ||
+					!utf8.ValidString(closeErr.Text) {
+					return 0, nil, ws.fail(&CloseError{Code: CloseProtocolError})
+				}
+			}
+			// echo close message
+			var code = closeErr.Code
+			if code == CloseNoStatusReceived {
+				code = CloseNormalClosure
+			}
+			ws.WriteClose(code, "")
+			ws.conn.Close()
+			return 0, nil, closeErr
+		case 0: // continuation
+			if messageType == 0 {
+				return 0, nil, ws.fail(&CloseError{Code: CloseProtocolError,
+					Text: "unexpected continuation frame"})
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.fail(&CloseError{Code: CloseProtocolError,
+					Text: "expected continuation frame"})
+			}
+			messageType = opcode
+		default:
+			return 0, nil, ws.fail(&CloseError{Code: CloseProtocolError,
+				Text: "unknown opcode"})
+		}
+		data = append(data, payload...)
+		if !fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(data) {
+			return 0, nil, ws.fail(&CloseError{Code: CloseInvalidPayloadData,
+				Text: "invalid utf-8"})
+		}
+		return messageType, data, nil
+	}
+}
+
+// validCloseCode returns true if the close code can be received in the close
+// message.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1011:
+		return code != 1004 && code != CloseNoStatusReceived &&
+			code != CloseAbnormalClosure
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail sends the close message if err is *CloseError and closes the
+// connection.
+func (ws *WebSocket) fail(err error) error {
+	if closeErr, ok := err.(*CloseError); ok {
+		ws.WriteClose(closeErr.Code, closeErr.Text)
+	} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+	}
+	ws.conn.Close()
+	return err
+}
+
+// readFrame reads the next frame from connection. The limit sets the maximum
+// size of the payload of the data frame.
+func (ws *WebSocket) readFrame(limit int64) (fin bool, opcode int, payload []byte, err error) {
+	var header [8]byte
+	if _, err = io.ReadFull(ws.reader, header[:2]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = int(header[0] & 0x0f)
+	if header[0]&0x70 != 0 {
+		err = &CloseError{Code: CloseProtocolError, Text: "reserved bits set"}
+		return
+	}
+	if header[1]&0x80 == 0 {
+		err = &CloseError{Code: CloseProtocolError, Text: "unmasked frame"}
+		return
+	}
+	var length = int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(ws.reader, header[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(header[:2]))
+	case 127:
+		if _, err = io.ReadFull(ws.reader, header[:8]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(header[:8]))
+	}
+	if length < 0 { // the most significant bit must be 0 (RFC 6455, 5.2)
+		err = &CloseError{Code: CloseProtocolError, Text: "bad frame length"}
+		return
+	}
+	if opcode >= CloseMessage {
+		if !fin || length > 125 {
+			err = &CloseError{Code: CloseProtocolError,
+				Text: "bad control frame"}
+			return
+		}
+	} else if length > limit {
+		err = &CloseError{Code: CloseMessageTooBig}
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// writeFrame writes a single unmasked frame to the connection.
+func (ws *WebSocket) writeFrame(fin bool, opcode int, payload []byte) error {
+	ws.frameMu.Lock()
+	defer ws.frameMu.Unlock()
+	if ws.closeSent {
+		return &CloseError{Code: CloseNormalClosure, Text: "connection closed"}
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+	var header = make([]byte, 2, 10+len(payload))
+	header[0] = byte(opcode)
+	if fin {
+		header[0] |= 0x80
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+// WriteMessage writes a data message to the connection.
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errors.New("websocket: bad message type")
+	}
+	ws.msgMu.Lock()
+	defer ws.msgMu.Unlock()
+	return ws.writeFrame(true, messageType, data)
+}
+
+// NextWriter returns a writer of the fragmented data message. Each call of the
+// Write method sends a separate frame, and the Close method completes the
+// message. Other messages are not sent until the writer is closed.
+func (ws *WebSocket) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, errors.New("websocket: bad message type")
+	}
+	ws.msgMu.Lock()
+	return &messageWriter{ws: ws, opcode: messageType}, nil
+}
+
+// messageWriter writes the fragmented message.
+type messageWriter struct {
+	ws     *WebSocket
+	opcode int
+	closed bool
+}
+
+// Write sends data as a message fragment.
+func (w *messageWriter) Write(data []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed writer")
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if err := w.ws.writeFrame(false, w.opcode, data); err != nil {
+		return 0, err
+	}
+	w.opcode = 0 // next frames are continuation
+	return len(data), nil
+}
+
+// Close sends the final fragment of the message.
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.ws.msgMu.Unlock()
+	return w.ws.writeFrame(true, w.opcode, nil)
+}
+
+// WriteJSON writes v as the text message using the Encoder.
+func (ws *WebSocket) WriteJSON(v interface{}) error {
+	var encoder = ws.Encoder
+	if encoder == nil {
+		encoder = defaultEncoder
+	}
+	var buf bytes.Buffer
+	var c = &Context{
+		Response: &response{
+			ResponseWriter: &frameResponse{header: make(http.Header)},
+			code:           http.StatusOK,
+			writer:         &buf,
+			// request without Accept-Encoding disables compression
+			request: &http.Request{Method: "GET", Header: make(http.Header)},
+		},
+		Request:       ws.request,
+		AllowMultiple: true,
+	}
+	if err := encoder(c, v); err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, buf.Bytes())
+}
+
+// frameResponse is the http.ResponseWriter used to encode WebSocket message.
+type frameResponse struct {
+	header http.Header
+}
+
+func (r *frameResponse) Header() http.Header            { return r.header }
+func (r *frameResponse) Write(data []byte) (int, error) { return len(data), nil }
+func (r *frameResponse) WriteHeader(int)                {}
+
+// ReadJSON reads the next data message and decodes it as JSON to v.
+func (ws *WebSocket) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Ping sends the ping message.
+func (ws *WebSocket) Ping(data []byte) error {
+	if len(data) > 125 {
+		return errors.New("websocket: control message too long")
+	}
+	return ws.writeFrame(true, PingMessage, data)
+}
+
+// WriteClose sends the close message with the specified code and text.
+func (ws *WebSocket) WriteClose(code int, text string) error {
+	var payload []byte
+	if code != CloseNoStatusReceived {
+		payload = make([]byte, 2, 2+len(text))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, text...)
+		if len(payload) > 125 {
+			payload = payload[:125]
+		}
+	}
+	return ws.writeFrame(true, CloseMessage, payload)
+}
+
+// Close sends the normal closure message, if it has not been sent yet, and
+// closes the connection.
+func (ws *WebSocket) Close() error {
+	ws.WriteClose(CloseNormalClosure, "")
+	return ws.conn.Close()
+}
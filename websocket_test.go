@@ -0,0 +1,249 @@
+package rest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// wsClientFrame returns the masked client frame.
+func wsClientFrame(fin bool, opcode int, payload []byte) []byte {
+	var frame = []byte{byte(opcode), 0x80}
+	if fin {
+		frame[0] |= 0x80
+	}
+	switch {
+	case len(payload) <= 125:
+		frame[1] |= byte(len(payload))
+	default:
+		frame[1] |= 126
+		frame = append(frame, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	}
+	var mask = []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// wsReadFrame reads the unmasked server frame.
+func wsReadFrame(t *testing.T, r *bufio.Reader) (int, []byte) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	var length = int(header[1] & 0x7f)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	var payload = make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return int(header[0] & 0x0f), payload
+}
+
+func TestWebSocket(t *testing.T) {
+	var done = make(chan error, 1)
+	var ts = httptest.NewServer(Handler(func(c *Context) error {
+		ws, err := c.Upgrade("chat")
+		if err != nil {
+			return err
+		}
+		ws.MaxMessageSize = 1000
+		for {
+			messageType, data, err := ws.ReadMessage()
+			if err != nil {
+				done <- err
+				return nil
+			}
+			if string(data) == "json" {
+				err = ws.WriteJSON(JSON{"type": messageType})
+			} else {
+				err = ws.WriteMessage(messageType, data)
+			}
+			if err != nil {
+				done <- err
+				return nil
+			}
+		}
+	}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Protocol: superchat, chat\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	var reader = bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal("bad status:", resp.Status)
+	}
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Error("bad accept key:", accept)
+	}
+	if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "chat" {
+		t.Error("bad protocol:", protocol)
+	}
+
+	// fragmented message with ping in the middle
+	conn.Write(wsClientFrame(false, TextMessage, []byte("Hel")))
+	conn.Write(wsClientFrame(true, PingMessage, []byte("ping")))
+	conn.Write(wsClientFrame(true, 0, []byte("lo")))
+	if opcode, data := wsReadFrame(t, reader); opcode != PongMessage || string(data) != "ping" {
+		t.Error("bad pong:", opcode, string(data))
+	}
+	if opcode, data := wsReadFrame(t, reader); opcode != TextMessage || string(data) != "Hello" {
+		t.Error("bad message:", opcode, string(data))
+	}
+
+	conn.Write(wsClientFrame(true, TextMessage, []byte("json")))
+	if opcode, data := wsReadFrame(t, reader); opcode != TextMessage ||
+		strings.TrimSpace(string(data)) != "{\n    \"type\": 1\n}" {
+		t.Errorf("bad json message: %d %q", opcode, data)
+	}
+
+	// message too big
+	conn.Write(wsClientFrame(true, BinaryMessage, make([]byte, 1001)))
+	opcode, data := wsReadFrame(t, reader)
+	if opcode != CloseMessage || binary.BigEndian.Uint16(data) != CloseMessageTooBig {
+		t.Error("bad close message:", opcode, data)
+	}
+	if err, ok := (<-done).(*CloseError); !ok || err.Code != CloseMessageTooBig {
+		t.Error("bad close error:", err)
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	var done = make(chan error, 1)
+	var ts = httptest.NewServer(Handler(func(c *Context) error {
+		ws, err := c.Upgrade()
+		if err != nil {
+			return err
+		}
+		_, _, err = ws.ReadMessage()
+		done <- err
+		return nil
+	}))
+	defer ts.Close()
+
+	// bad handshake
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Error("bad status:", resp.Status)
+	}
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	var reader = bufio.NewReader(conn)
+	if _, err = http.ReadResponse(reader, nil); err != nil {
+		t.Fatal(err)
+	}
+	var payload = []byte{0x03, 0xe9} // 1001
+	payload = append(payload, "bye"...)
+	conn.Write(wsClientFrame(true, CloseMessage, payload))
+	opcode, data := wsReadFrame(t, reader)
+	if opcode != CloseMessage || binary.BigEndian.Uint16(data) != CloseGoingAway {
+		t.Error("bad close echo:", opcode, data)
+	}
+	if err, ok := (<-done).(*CloseError); !ok || err.Code != CloseGoingAway ||
+		err.Text != "bye" {
+		t.Error("bad close error:", err)
+	}
+}
+
+func TestWebSocketBadLength(t *testing.T) {
+	for _, opcode := range []byte{PingMessage, BinaryMessage} {
+		server, client := net.Pipe()
+		var ws = &WebSocket{conn: server, reader: bufio.NewReader(server)}
+		// 64-bit length with the most significant bit set
+		go client.Write([]byte{0x80 | opcode, 0xff, 0x80, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4})
+		go io.Copy(io.Discard, client) // close message
+		_, _, err := ws.ReadMessage()
+		if err, ok := err.(*CloseError); !ok || err.Code != CloseProtocolError {
+			t.Error("bad frame length error:", opcode, err)
+		}
+		client.Close()
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	var handler = func(c *Context) error {
+		ws, err := c.Upgrade()
+		if err != nil {
+			return err
+		}
+		return ws.Close()
+	}
+	var mux = new(ServeMux)
+	mux.Handle("GET", "/", handler)
+	var ts = httptest.NewServer(mux)
+	defer ts.Close()
+
+	var handshake = func(path, origin string) int {
+		conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, "GET "+path+" HTTP/1.1\r\n"+
+			"Host: example.com\r\n"+
+			"Origin: "+origin+"\r\n"+
+			"Upgrade: websocket\r\n"+
+			"Connection: Upgrade\r\n"+
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+			"Sec-WebSocket-Version: 13\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+	if code := handshake("/", "https://example.com"); code != http.StatusSwitchingProtocols {
+		t.Error("same origin is rejected:", code)
+	}
+	if code := handshake("/", "https://evil.com"); code != http.StatusForbidden {
+		t.Error("cross origin is allowed:", code)
+	}
+	mux.CheckOrigin = func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://evil.com"
+	}
+	if code := handshake("/", "https://evil.com"); code != http.StatusSwitchingProtocols {
+		t.Error("allowed origin is rejected:", code)
+	}
+	if code := handshake("/", "https://example.com"); code != http.StatusForbidden {
+		t.Error("origin check is not used:", code)
+	}
+}